@@ -4,9 +4,15 @@ import (
 	"errors"
 
 	kooky "github.com/kgoins/kooky/pkg"
+	"github.com/kgoins/kooky/pkg/brave"
 	"github.com/kgoins/kooky/pkg/chrome"
+	"github.com/kgoins/kooky/pkg/chromium"
+	"github.com/kgoins/kooky/pkg/edge"
 	"github.com/kgoins/kooky/pkg/firefox"
+	"github.com/kgoins/kooky/pkg/opera"
 	"github.com/kgoins/kooky/pkg/safari"
+	"github.com/kgoins/kooky/pkg/vivaldi"
+	"github.com/kgoins/kooky/pkg/yandex"
 )
 
 // BuildBrowserKookyReader constructs the appropriate reader based on the requested browser type.
@@ -18,6 +24,18 @@ func BuildBrowserKookyReader(browserType string) (kooky.BrowserKookyReader, erro
 		return chrome.NewCookieReader(), nil
 	case "safari":
 		return safari.NewCookieReader(), nil
+	case "chromium":
+		return chromium.NewCookieReader(), nil
+	case "brave":
+		return brave.NewCookieReader(), nil
+	case "edge":
+		return edge.NewCookieReader(), nil
+	case "vivaldi":
+		return vivaldi.NewCookieReader(), nil
+	case "opera":
+		return opera.NewCookieReader(), nil
+	case "yandex":
+		return yandex.NewCookieReader(), nil
 	default:
 		return nil, errors.New("Unsupported browser type")
 	}
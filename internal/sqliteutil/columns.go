@@ -0,0 +1,171 @@
+package sqliteutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// tableConstraints are the keywords starting a table constraint rather
+// than a column definition in a CREATE TABLE statement.
+var tableConstraints = []string{"CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN"}
+
+// Columns maps the column names of a table to their index in its records.
+type Columns map[string]int
+
+// ReadColumns returns the columns of the named table of the database in r.
+func ReadColumns(r io.ReaderAt, table string) (Columns, error) {
+	sql, err := TableSQL(r, table)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := ParseColumns(sql)
+	if err != nil {
+		return nil, fmt.Errorf("table %q: %v", table, err)
+	}
+
+	columns := make(Columns, len(names))
+	for i, name := range names {
+		columns[name] = i
+	}
+	return columns, nil
+}
+
+// Has reports whether the table has a column with any of the given names.
+func (c Columns) Has(names ...string) bool {
+	_, ok := c.index(names)
+	return ok
+}
+
+// Value returns the value of the first existing column among names in the
+// record values. It returns nil if no such column exists, or if the record
+// predates the column being added by ALTER TABLE and is therefore shorter.
+func (c Columns) Value(values []interface{}, names ...string) interface{} {
+	i, ok := c.index(names)
+	if !ok || i >= len(values) {
+		return nil
+	}
+
+	return values[i]
+}
+
+// ColumnInt returns the integer value of the first existing column among
+// names in the record values, or def if no such column exists or the
+// record predates it.
+func ColumnInt(columns Columns, values []interface{}, def int64, names ...string) int64 {
+	i, ok := ToInt64(columns.Value(values, names...))
+	if !ok {
+		return def
+	}
+	return i
+}
+
+func (c Columns) index(names []string) (int, bool) {
+	for _, name := range names {
+		if i, ok := c[name]; ok {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+// ParseColumns returns the column names, in order, of a CREATE TABLE statement.
+func ParseColumns(sql string) ([]string, error) {
+	start := strings.Index(sql, "(")
+	end := strings.LastIndex(sql, ")")
+	if start < 0 || end < start {
+		return nil, errors.New("no column definitions in CREATE TABLE statement")
+	}
+
+	var names []string
+	for _, def := range splitDefinitions(sql[start+1 : end]) {
+		name := firstToken(def)
+		if name == "" {
+			continue
+		}
+
+		isConstraint := false
+		for _, keyword := range tableConstraints {
+			if strings.EqualFold(name, keyword) {
+				isConstraint = true
+				break
+			}
+		}
+		if isConstraint {
+			continue
+		}
+
+		names = append(names, unquote(name))
+	}
+
+	if len(names) == 0 {
+		return nil, errors.New("no columns in CREATE TABLE statement")
+	}
+	return names, nil
+}
+
+// splitDefinitions splits the body of a CREATE TABLE statement at the
+// commas that are not nested in parentheses or quotes.
+func splitDefinitions(body string) []string {
+	var defs []string
+	depth := 0
+	var quote byte
+	last := 0
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '[':
+			quote = ']'
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			defs = append(defs, strings.TrimSpace(body[last:i]))
+			last = i + 1
+		}
+	}
+
+	return append(defs, strings.TrimSpace(body[last:]))
+}
+
+// firstToken returns the first, possibly quoted, token of a definition.
+func firstToken(def string) string {
+	if def == "" {
+		return ""
+	}
+
+	closing := map[byte]byte{'"': '"', '`': '`', '[': ']', '\'': '\''}
+	if end, ok := closing[def[0]]; ok {
+		if i := strings.IndexByte(def[1:], end); i >= 0 {
+			return def[:i+2]
+		}
+		return def
+	}
+
+	if i := strings.IndexAny(def, " \t\r\n("); i >= 0 {
+		return def[:i]
+	}
+	return def
+}
+
+func unquote(name string) string {
+	if len(name) >= 2 {
+		switch name[0] {
+		case '"', '`', '[', '\'':
+			return name[1 : len(name)-1]
+		}
+	}
+
+	return name
+}
@@ -0,0 +1,57 @@
+package sqliteutil
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseColumns(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want []string
+	}{
+		{
+			"CREATE TABLE cookies(creation_utc INTEGER NOT NULL,host_key TEXT NOT NULL,encrypted_value BLOB DEFAULT '',UNIQUE (host_key, name, path))",
+			[]string{"creation_utc", "host_key", "encrypted_value"},
+		},
+		{
+			"CREATE TABLE moz_cookies (id INTEGER PRIMARY KEY, originAttributes TEXT NOT NULL DEFAULT '', name TEXT, CONSTRAINT moz_uniqueid UNIQUE (name, host, path, originAttributes))",
+			[]string{"id", "originAttributes", "name"},
+		},
+		{
+			"CREATE TABLE \"quoted\" (\"a b\" TEXT, [c] INT, `d` DEFAULT 'x,y', e CHECK (e IN (1, 2)), primary key (a))",
+			[]string{"a b", "c", "d", "e"},
+		},
+	}
+
+	for _, test := range tests {
+		got, err := ParseColumns(test.sql)
+		if err != nil {
+			t.Errorf("%s: %v", test.sql, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: want %q; got %q", test.sql, test.want, got)
+		}
+	}
+}
+
+func TestColumnInt(t *testing.T) {
+	columns := Columns{"is_secure": 0, "secure": 1, "samesite": 2}
+	values := []interface{}{int8(1), int64(0)}
+
+	tests := []struct {
+		names []string
+		want  int64
+	}{
+		{[]string{"is_secure", "secure"}, 1},
+		{[]string{"secure"}, 0},
+		{[]string{"samesite"}, -1}, // the record predates the column
+		{[]string{"missing"}, -1},
+	}
+	for _, test := range tests {
+		if got := ColumnInt(columns, values, -1, test.names...); got != test.want {
+			t.Errorf("%v: want %d; got %d", test.names, test.want, got)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package sqliteutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// The go-sqlite/sqlite3 package hides sqlite_master from its callers, so the
+// definitions of the tables are read here with a minimal b-tree walker.
+// https://www.sqlite.org/fileformat2.html
+
+const (
+	headerSize           = 100
+	leafTablePage        = 0x0d
+	interiorTablePage    = 0x05
+	masterRootPage       = 1
+	maxBtreeDepth        = 32
+	sqliteMagic          = "SQLite format 3\x00"
+	masterTypeColumn     = 0
+	masterNameColumn     = 1
+	masterRootPageColumn = 3
+	masterSQLColumn      = 4
+	masterColumnsLength  = 5
+)
+
+// TableSQL returns the CREATE TABLE statement of the named table, as
+// recorded in the sqlite_master table of the database in r.
+func TableSQL(r io.ReaderAt, table string) (string, error) {
+	db, err := newDatabase(r)
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := db.masterEntry(table)
+	if err != nil {
+		return "", err
+	}
+	if entry == nil {
+		return "", fmt.Errorf("unknown table %q", table)
+	}
+	sql, _ := entry[masterSQLColumn].(string)
+
+	return sql, nil
+}
+
+// MetaVersion returns the version recorded in the meta table browsers keep
+// next to their cookies in the database in r, or 0 if it has none.
+func MetaVersion(r io.ReaderAt) (int, error) {
+	db, err := newDatabase(r)
+	if err != nil {
+		return 0, err
+	}
+
+	entry, err := db.masterEntry("meta")
+	if err != nil || entry == nil {
+		return 0, err
+	}
+	rootPage, ok := ToInt64(entry[masterRootPageColumn])
+	if !ok || rootPage <= 0 || rootPage > math.MaxUint32 {
+		return 0, fmt.Errorf("meta: invalid root page %v", entry[masterRootPageColumn])
+	}
+
+	var version int
+	err = db.visitTable(uint32(rootPage), 0, func(_ int64, values []interface{}) error {
+		if len(values) >= 2 && values[0] == "version" {
+			version, _ = strconv.Atoi(fmt.Sprint(values[1]))
+		}
+		return nil
+	})
+	return version, err
+}
+
+type database struct {
+	r          io.ReaderAt
+	pageSize   int
+	usableSize int
+}
+
+func newDatabase(r io.ReaderAt) (*database, error) {
+	header := make([]byte, headerSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("error reading sqlite header: %v", err)
+	}
+	if string(header[:len(sqliteMagic)]) != sqliteMagic {
+		return nil, errors.New("not a sqlite database")
+	}
+
+	pageSize := int(binary.BigEndian.Uint16(header[16:18]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+
+	return &database{
+		r:          r,
+		pageSize:   pageSize,
+		usableSize: pageSize - int(header[20]),
+	}, nil
+}
+
+func (db *database) page(pageNo uint32) ([]byte, error) {
+	if pageNo == 0 {
+		return nil, errors.New("invalid page number 0")
+	}
+
+	page := make([]byte, db.pageSize)
+	if _, err := db.r.ReadAt(page, int64(pageNo-1)*int64(db.pageSize)); err != nil {
+		return nil, fmt.Errorf("error reading page %d: %v", pageNo, err)
+	}
+	return page, nil
+}
+
+// masterEntry returns the sqlite_master row of the named table, or nil if
+// there is none.
+func (db *database) masterEntry(table string) ([]interface{}, error) {
+	var entry []interface{}
+	err := db.visitTable(masterRootPage, 0, func(_ int64, values []interface{}) error {
+		if len(values) < masterColumnsLength {
+			return fmt.Errorf("sqlite_master: expected %d columns, got %d", masterColumnsLength, len(values))
+		}
+		if values[masterTypeColumn] == "table" && values[masterNameColumn] == table {
+			entry = values
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// visitTable calls f with the rowid and decoded record of every row in the
+// table b-tree rooted at pageNo, in rowid order.
+func (db *database) visitTable(pageNo uint32, depth int, f func(int64, []interface{}) error) error {
+	if depth > maxBtreeDepth {
+		return errors.New("b-tree too deep")
+	}
+
+	page, err := db.page(pageNo)
+	if err != nil {
+		return err
+	}
+
+	offset := 0
+	if pageNo == 1 {
+		offset = headerSize
+	}
+	if len(page) < offset+12 {
+		return fmt.Errorf("page %d too short", pageNo)
+	}
+
+	kind := page[offset]
+	numCells := int(binary.BigEndian.Uint16(page[offset+3 : offset+5]))
+	pointers := offset + 8
+	if kind == interiorTablePage {
+		pointers = offset + 12
+	}
+	if len(page) < pointers+2*numCells {
+		return fmt.Errorf("page %d: too many cells", pageNo)
+	}
+
+	for i := 0; i < numCells; i++ {
+		cell := int(binary.BigEndian.Uint16(page[pointers+2*i:]))
+		if cell >= len(page) {
+			return fmt.Errorf("page %d: cell %d out of range", pageNo, i)
+		}
+
+		switch kind {
+		case interiorTablePage:
+			if cell+4 > len(page) {
+				return fmt.Errorf("page %d: cell %d out of range", pageNo, i)
+			}
+			if err := db.visitTable(binary.BigEndian.Uint32(page[cell:]), depth+1, f); err != nil {
+				return err
+			}
+		case leafTablePage:
+			rowID, payload, err := db.payload(page, cell)
+			if err != nil {
+				return fmt.Errorf("page %d, cell %d: %v", pageNo, i, err)
+			}
+			values, err := decodeRecord(payload)
+			if err != nil {
+				return fmt.Errorf("page %d, cell %d: %v", pageNo, i, err)
+			}
+			if err := f(rowID, values); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("page %d: unexpected page type 0x%02x", pageNo, kind)
+		}
+	}
+
+	if kind == interiorTablePage {
+		return db.visitTable(binary.BigEndian.Uint32(page[offset+8:]), depth+1, f)
+	}
+	return nil
+}
+
+// payload returns the rowid and full payload of the leaf table cell at
+// offset cell, following overflow pages as necessary.
+func (db *database) payload(page []byte, cell int) (int64, []byte, error) {
+	size, n := varint(page[cell:])
+	if n == 0 {
+		return 0, nil, errors.New("invalid payload size")
+	}
+	cell += n
+	rowID, n := varint(page[cell:])
+	if n == 0 {
+		return 0, nil, errors.New("invalid rowid")
+	}
+	cell += n
+
+	total := int(size)
+	local := db.localPayloadSize(total)
+	if cell+local > len(page) {
+		return 0, nil, errors.New("payload out of range")
+	}
+
+	payload := append([]byte{}, page[cell:cell+local]...)
+	if local == total {
+		return rowID, payload, nil
+	}
+
+	if cell+local+4 > len(page) {
+		return 0, nil, errors.New("overflow pointer out of range")
+	}
+	next := binary.BigEndian.Uint32(page[cell+local:])
+	for len(payload) < total {
+		if next == 0 {
+			return 0, nil, errors.New("overflow chain too short")
+		}
+		overflow, err := db.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		next = binary.BigEndian.Uint32(overflow)
+
+		chunk := db.usableSize - 4
+		if remaining := total - len(payload); chunk > remaining {
+			chunk = remaining
+		}
+		payload = append(payload, overflow[4:4+chunk]...)
+	}
+
+	return rowID, payload, nil
+}
+
+func (db *database) localPayloadSize(total int) int {
+	maxLocal := db.usableSize - 35
+	if total <= maxLocal {
+		return total
+	}
+
+	minLocal := (db.usableSize-12)*32/255 - 23
+	local := minLocal + (total-minLocal)%(db.usableSize-4)
+	if local > maxLocal {
+		return minLocal
+	}
+	return local
+}
+
+// decodeRecord decodes a record in the sqlite record format. Integers are
+// returned as int64, floats as float64, text as string and blobs as []byte.
+func decodeRecord(payload []byte) ([]interface{}, error) {
+	headerLen, n := varint(payload)
+	if n == 0 || int(headerLen) > len(payload) || int(headerLen) < n {
+		return nil, errors.New("invalid record header")
+	}
+
+	var types []int64
+	for pos := n; pos < int(headerLen); {
+		serialType, n := varint(payload[pos:headerLen])
+		if n == 0 {
+			return nil, errors.New("invalid record serial type")
+		}
+		types = append(types, serialType)
+		pos += n
+	}
+
+	values := make([]interface{}, 0, len(types))
+	body := payload[headerLen:]
+	for _, serialType := range types {
+		size := serialTypeSize(serialType)
+		if size > len(body) {
+			return nil, errors.New("record body too short")
+		}
+		data := body[:size]
+		body = body[size:]
+
+		switch {
+		case serialType == 0:
+			values = append(values, nil)
+		case serialType >= 1 && serialType <= 6:
+			var v int64
+			for _, b := range data {
+				v = v<<8 | int64(b)
+			}
+			// sign-extend
+			shift := uint(64 - 8*size)
+			values = append(values, v<<shift>>shift)
+		case serialType == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(data)))
+		case serialType == 8:
+			values = append(values, int64(0))
+		case serialType == 9:
+			values = append(values, int64(1))
+		case serialType >= 12 && serialType%2 == 0:
+			values = append(values, append([]byte{}, data...))
+		case serialType >= 13:
+			values = append(values, string(data))
+		default:
+			return nil, fmt.Errorf("invalid serial type %d", serialType)
+		}
+	}
+
+	return values, nil
+}
+
+func serialTypeSize(serialType int64) int {
+	switch serialType {
+	case 0, 8, 9, 10, 11:
+		return 0
+	case 1, 2, 3, 4:
+		return int(serialType)
+	case 5:
+		return 6
+	case 6, 7:
+		return 8
+	default:
+		if serialType >= 12 {
+			return int((serialType - 12) / 2)
+		}
+		return 0
+	}
+}
+
+// varint decodes a sqlite variable-length integer, returning the value and
+// the number of bytes read, or 0 bytes if buf is too short.
+func varint(buf []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		if i >= len(buf) {
+			return 0, 0
+		}
+		if i == 8 {
+			v = v<<8 | uint64(buf[i])
+			return int64(v), 9
+		}
+		v = v<<7 | uint64(buf[i]&0x7f)
+		if buf[i]&0x80 == 0 {
+			return int64(v), i + 1
+		}
+	}
+	return int64(v), 9
+}
@@ -0,0 +1,37 @@
+package sqliteutil
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kgoins/kooky/internal/testutils"
+)
+
+func TestMetaVersion(t *testing.T) {
+	tests := []struct {
+		fixture string
+		want    int
+	}{
+		{"chrome-cookies-124.sqlite", 23},
+		{"chrome-cookies-66.sqlite", 10},
+		{"firefox-cookies.sqlite", 0},
+	}
+
+	for _, test := range tests {
+		path, err := testutils.GetTestDataFilePath(test.fixture)
+		if err != nil {
+			t.Fatalf("Failed to load test data file")
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		version, err := MetaVersion(f)
+		f.Close()
+		if err != nil {
+			t.Errorf("%s: %v", test.fixture, err)
+		} else if version != test.want {
+			t.Errorf("%s: want version %d; got %d", test.fixture, test.want, version)
+		}
+	}
+}
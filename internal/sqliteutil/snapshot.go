@@ -0,0 +1,227 @@
+package sqliteutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/kgoins/kooky/internal/storefs"
+)
+
+// Browsers keep their cookie databases open, with recent changes still in
+// the write-ahead log or, for an interrupted transaction, with the original
+// pages in the rollback journal. ReadSnapshot applies either to an in-memory
+// copy of the database, leaving the files on disk untouched.
+// https://www.sqlite.org/fileformat2.html#the_write_ahead_log
+// https://www.sqlite.org/fileformat2.html#the_rollback_journal
+
+const (
+	walSuffix     = "-wal"
+	journalSuffix = "-journal"
+
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+	walMagicLE         = 0x377f0682
+	walMagicBE         = 0x377f0683
+
+	journalHeaderSize = 28
+	journalMagic      = "\xd9\xd5\x05\xf9\x20\xa1\x63\xd7"
+
+	headerDbSizeOffset = 28
+)
+
+// ReadSnapshot returns the database name in files as it would be seen by a
+// reader: with the committed frames of its write-ahead log applied, or with
+// a hot rollback journal rolled back.
+func ReadSnapshot(files storefs.FS, name string) (*bytes.Reader, error) {
+	db, err := files.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	journal, err := files.ReadFile(name + journalSuffix)
+	if err == nil {
+		db = applyJournal(db, journal)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	wal, err := files.ReadFile(name + walSuffix)
+	if err == nil {
+		if db, err = applyWAL(db, wal); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return bytes.NewReader(db), nil
+}
+
+// applyWAL returns db with the frames of every transaction committed to wal
+// written to it. Frames after the last valid commit are ignored, as sqlite
+// does.
+func applyWAL(db []byte, wal []byte) ([]byte, error) {
+	if len(wal) < walHeaderSize {
+		return db, nil
+	}
+
+	var order binary.ByteOrder
+	switch binary.BigEndian.Uint32(wal) {
+	case walMagicBE:
+		order = binary.BigEndian
+	case walMagicLE:
+		order = binary.LittleEndian
+	default:
+		return nil, errors.New("invalid write-ahead log header")
+	}
+
+	pageSize := int(binary.BigEndian.Uint32(wal[8:]))
+	if pageSize == 1 {
+		pageSize = 65536
+	}
+	if pageSize < 512 || pageSize&(pageSize-1) != 0 {
+		return nil, errors.New("invalid write-ahead log page size")
+	}
+	salt := wal[16:24]
+
+	s0, s1 := walChecksum(order, wal[:24], 0, 0)
+	if s0 != binary.BigEndian.Uint32(wal[24:]) || s1 != binary.BigEndian.Uint32(wal[28:]) {
+		// A log whose header fails its checksum has been reset and holds
+		// no valid frames.
+		return db, nil
+	}
+
+	// Frames are only applied once the commit frame ending their
+	// transaction has been seen.
+	type frame struct {
+		pageNo uint32
+		data   []byte
+	}
+	var pending []frame
+	// Pages past the end of db are only ever written by the log, so no
+	// commit can grow the database beyond maxPages.
+	maxPages := len(db)/pageSize + (len(wal)-walHeaderSize)/(walFrameHeaderSize+pageSize)
+	for offset := walHeaderSize; offset+walFrameHeaderSize+pageSize <= len(wal); offset += walFrameHeaderSize + pageSize {
+		header := wal[offset : offset+walFrameHeaderSize]
+		data := wal[offset+walFrameHeaderSize : offset+walFrameHeaderSize+pageSize]
+
+		if !bytes.Equal(header[8:16], salt) {
+			break
+		}
+		s0, s1 = walChecksum(order, header[:8], s0, s1)
+		s0, s1 = walChecksum(order, data, s0, s1)
+		if s0 != binary.BigEndian.Uint32(header[16:]) || s1 != binary.BigEndian.Uint32(header[20:]) {
+			break
+		}
+
+		pending = append(pending, frame{binary.BigEndian.Uint32(header), data})
+
+		if dbSize := binary.BigEndian.Uint32(header[4:]); dbSize != 0 {
+			if int64(dbSize) > int64(maxPages) {
+				return nil, fmt.Errorf("write-ahead log commits a database of %d pages, but holds at most %d", dbSize, maxPages)
+			}
+			for _, f := range pending {
+				if f.pageNo == 0 || f.pageNo > dbSize {
+					return nil, fmt.Errorf("invalid page number %d in write-ahead log of %d pages", f.pageNo, dbSize)
+				}
+			}
+			for _, f := range pending {
+				db = writePage(db, pageSize, f.pageNo, f.data)
+			}
+			pending = pending[:0]
+
+			if size := int(dbSize) * pageSize; len(db) > size {
+				db = db[:size]
+			}
+			if len(db) >= headerDbSizeOffset+4 {
+				binary.BigEndian.PutUint32(db[headerDbSizeOffset:], dbSize)
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// walChecksum continues the checksum s0, s1 over data, whose length is a
+// multiple of 8.
+func walChecksum(order binary.ByteOrder, data []byte, s0, s1 uint32) (uint32, uint32) {
+	for i := 0; i+8 <= len(data); i += 8 {
+		s0 += order.Uint32(data[i:]) + s1
+		s1 += order.Uint32(data[i+4:]) + s0
+	}
+	return s0, s1
+}
+
+// applyJournal returns db with the original pages saved in a hot rollback
+// journal restored. A journal that was committed, and so zeroed or
+// truncated, leaves db unchanged.
+func applyJournal(db []byte, journal []byte) []byte {
+	for offset := 0; offset+journalHeaderSize <= len(journal); {
+		header := journal[offset : offset+journalHeaderSize]
+		if string(header[:8]) != journalMagic {
+			break
+		}
+
+		records := int64(binary.BigEndian.Uint32(header[8:]))
+		nonce := binary.BigEndian.Uint32(header[12:])
+		dbSize := int(binary.BigEndian.Uint32(header[16:]))
+		sectorSize := int(binary.BigEndian.Uint32(header[20:]))
+		pageSize := int(binary.BigEndian.Uint32(header[24:]))
+		if sectorSize < journalHeaderSize || pageSize < 512 {
+			break
+		}
+
+		recordSize := 4 + pageSize + 4
+		start := offset + sectorSize
+		if records == 0xffffffff {
+			records = int64((len(journal) - start) / recordSize)
+		}
+
+		for i := int64(0); i < records; i++ {
+			record := start + int(i)*recordSize
+			if record+recordSize > len(journal) {
+				break
+			}
+			pageNo := binary.BigEndian.Uint32(journal[record:])
+			data := journal[record+4 : record+4+pageSize]
+			checksum := binary.BigEndian.Uint32(journal[record+4+pageSize:])
+			if pageNo == 0 || checksum != journalChecksum(nonce, data) {
+				break
+			}
+			db = writePage(db, pageSize, pageNo, data)
+		}
+
+		if size := dbSize * pageSize; len(db) > size {
+			db = db[:size]
+		}
+
+		// Further segments start at the next sector boundary.
+		next := start + int(records)*recordSize
+		offset = (next + sectorSize - 1) / sectorSize * sectorSize
+	}
+
+	return db
+}
+
+// journalChecksum is the checksum of a journal record: the nonce plus every
+// 200th byte of the page, counting down from its end.
+func journalChecksum(nonce uint32, data []byte) uint32 {
+	checksum := nonce
+	for i := len(data) - 200; i > 0; i -= 200 {
+		checksum += uint32(data[i])
+	}
+	return checksum
+}
+
+// writePage writes data as page pageNo of db, growing db as necessary.
+func writePage(db []byte, pageSize int, pageNo uint32, data []byte) []byte {
+	offset := int(pageNo-1) * pageSize
+	if end := offset + pageSize; end > len(db) {
+		db = append(db, make([]byte, end-len(db))...)
+	}
+	copy(db[offset:], data)
+	return db
+}
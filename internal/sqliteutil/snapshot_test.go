@@ -0,0 +1,65 @@
+package sqliteutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildWAL returns a big-endian write-ahead log of one transaction writing
+// pages of pageSize bytes to the page numbers pageNos, in a database of
+// dbSize pages.
+func buildWAL(pageSize int, dbSize uint32, pageNos ...uint32) []byte {
+	wal := make([]byte, walHeaderSize)
+	binary.BigEndian.PutUint32(wal, walMagicBE)
+	binary.BigEndian.PutUint32(wal[4:], 3007000)
+	binary.BigEndian.PutUint32(wal[8:], uint32(pageSize))
+	copy(wal[16:24], "saltsalt")
+	s0, s1 := walChecksum(binary.BigEndian, wal[:24], 0, 0)
+	binary.BigEndian.PutUint32(wal[24:], s0)
+	binary.BigEndian.PutUint32(wal[28:], s1)
+
+	for i, pageNo := range pageNos {
+		header := make([]byte, walFrameHeaderSize)
+		binary.BigEndian.PutUint32(header, pageNo)
+		if i == len(pageNos)-1 {
+			binary.BigEndian.PutUint32(header[4:], dbSize)
+		}
+		copy(header[8:16], "saltsalt")
+		data := bytes.Repeat([]byte{byte(i + 1)}, pageSize)
+		s0, s1 = walChecksum(binary.BigEndian, header[:8], s0, s1)
+		s0, s1 = walChecksum(binary.BigEndian, data, s0, s1)
+		binary.BigEndian.PutUint32(header[16:], s0)
+		binary.BigEndian.PutUint32(header[20:], s1)
+		wal = append(append(wal, header...), data...)
+	}
+	return wal
+}
+
+func TestApplyWAL(t *testing.T) {
+	const pageSize = 512
+	db := make([]byte, 2*pageSize)
+
+	applied, err := applyWAL(db, buildWAL(pageSize, 3, 2, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != 3*pageSize || applied[pageSize] != 1 || applied[2*pageSize] != 2 {
+		t.Errorf("want pages 2 and 3 written to a database of 3 pages; got %d bytes", len(applied))
+	}
+
+	tests := []struct {
+		name    string
+		dbSize  uint32
+		pageNos []uint32
+	}{
+		{"page 0", 2, []uint32{0}},
+		{"page past the end", 2, []uint32{1, 3}},
+		{"huge page", 0xffffffff, []uint32{0xfffffff0}},
+	}
+	for _, test := range tests {
+		if _, err := applyWAL(db, buildWAL(pageSize, test.dbSize, test.pageNos...)); err == nil {
+			t.Errorf("%s: want an error for a corrupt write-ahead log", test.name)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Package sqliteutil contains helpers for reading records from the sqlite
+// databases browsers keep their cookies in.
+package sqliteutil
+
+import (
+	"fmt"
+
+	"github.com/go-sqlite/sqlite3"
+)
+
+// ToInt64 converts an integer column value to an int64, whatever its
+// storage width. It reports false if value is not an integer.
+func ToInt64(value interface{}) (int64, bool) {
+	switch i := value.(type) {
+	case int:
+		return int64(i), true
+	case int8:
+		return int64(i), true
+	case int16:
+		return int64(i), true
+	case int32:
+		return int64(i), true
+	case int64:
+		return i, true
+	case uint32:
+		return int64(i), true
+	case uint64:
+		return int64(i), true
+	default:
+		return 0, false
+	}
+}
+
+// VisitTableRecords calls db.VisitTableRecords, turning the panics the
+// sqlite3 package raises on malformed databases into errors.
+func VisitTableRecords(db *sqlite3.DbFile, table string, f func(*int64, sqlite3.Record) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("sqlite3: %v", r)
+		}
+	}()
+
+	return db.VisitTableRecords(table, f)
+}
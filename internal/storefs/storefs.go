@@ -0,0 +1,91 @@
+// Package storefs gives the cookie readers access to a cookie store and the
+// files next to it, such as its write-ahead log or the browser's Local
+// State, whether they are on disk, in an fs.FS or in memory.
+package storefs
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// FS is a file system holding a cookie store. Names use the path syntax of
+// the file system: native paths on disk and slash-separated paths otherwise.
+type FS interface {
+	ReadFile(name string) ([]byte, error)
+	Exists(name string) bool
+
+	Join(elem ...string) string
+	Dir(name string) string
+	Base(name string) string
+}
+
+// OS returns the file system of the host.
+func OS() FS {
+	return osFS{}
+}
+
+type osFS struct{}
+
+func (osFS) ReadFile(name string) ([]byte, error) {
+	return ioutil.ReadFile(name)
+}
+
+func (osFS) Exists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func (osFS) Join(elem ...string) string { return filepath.Join(elem...) }
+func (osFS) Dir(name string) string     { return filepath.Dir(name) }
+func (osFS) Base(name string) string    { return filepath.Base(name) }
+
+// FromFS returns the file system fsys, e.g. an archive or embedded files.
+func FromFS(fsys fs.FS) FS {
+	return ioFS{fsys}
+}
+
+type ioFS struct {
+	fsys fs.FS
+}
+
+func (f ioFS) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(f.fsys, name)
+}
+
+func (f ioFS) Exists(name string) bool {
+	_, err := fs.Stat(f.fsys, name)
+	return err == nil
+}
+
+func (ioFS) Join(elem ...string) string { return path.Join(elem...) }
+func (ioFS) Dir(name string) string     { return path.Dir(name) }
+func (ioFS) Base(name string) string    { return path.Base(name) }
+
+// Memory returns a file system holding only data, named "". Stores read
+// from it have nothing next to them.
+func Memory(data []byte) FS {
+	return memoryFS{data}
+}
+
+type memoryFS struct {
+	data []byte
+}
+
+func (f memoryFS) ReadFile(name string) ([]byte, error) {
+	if name != "" {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	// Readers may modify what they read, e.g. to apply a write-ahead log.
+	return append([]byte(nil), f.data...), nil
+}
+
+func (f memoryFS) Exists(name string) bool {
+	return name == ""
+}
+
+func (memoryFS) Join(elem ...string) string { return path.Join(elem...) }
+func (memoryFS) Dir(name string) string     { return path.Dir(name) }
+func (memoryFS) Base(name string) string    { return path.Base(name) }
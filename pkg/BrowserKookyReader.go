@@ -1,19 +1,26 @@
 package kooky
 
-import "time"
-
 // BrowserKookyReader is an object that allows read access to cookies
 // installed on the local operating system.
 type BrowserKookyReader interface {
-	ReadCookies(
-		filename string,
-		domainFilter string,
-		nameFilter string,
-		expireAfter time.Time,
-	) ([]*Cookie, error)
+	// ReadCookies reads the cookies accepted by every filter.
+	ReadCookies(filename string, filters ...Filter) ([]*Cookie, error)
+
+	// ReadCookiesLenient reads every cookie it can, reporting the cookies
+	// it could not read instead of failing. The error is only set if the
+	// store as a whole could not be read.
+	ReadCookiesLenient(filename string, filters ...Filter) ([]*Cookie, []CookieError, error)
 
 	ReadAllCookies(filePath string) ([]*Cookie, error)
 
 	GetDefaultInstallPath(operatingSystem string) (string, error)
 	GetDefaultCookieFilePath(operatingSystem string) (string, error)
+
+	// ListProfiles returns the profiles of the current user that have a
+	// cookie store.
+	ListProfiles(operatingSystem string) ([]Profile, error)
+
+	// GetProfileCookieFilePath returns the cookie file of the profile with
+	// the given ID or display name.
+	GetProfileCookieFilePath(operatingSystem string, profile string) (string, error)
 }
@@ -0,0 +1,235 @@
+package kooky
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Cookie-Editor and EditThisCookie browser extensions import and export
+// cookies as a JSON array of the browser extension API's cookies.Cookie:
+// https://developer.chrome.com/docs/extensions/reference/api/cookies#type-Cookie
+
+// cookieEditorCookie is a cookie in the Cookie-Editor JSON format.
+type cookieEditorCookie struct {
+	Domain           string   `json:"domain"`
+	ExpirationDate   *float64 `json:"expirationDate,omitempty"`
+	HostOnly         bool     `json:"hostOnly"`
+	HttpOnly         bool     `json:"httpOnly"`
+	Name             string   `json:"name"`
+	Path             string   `json:"path"`
+	SameSite         *string  `json:"sameSite"`
+	Secure           bool     `json:"secure"`
+	Session          bool     `json:"session"`
+	StoreID          string   `json:"storeId"`
+	Value            string   `json:"value"`
+	FirstPartyDomain string   `json:"firstPartyDomain,omitempty"`
+
+	PartitionKey *cookieEditorPartitionKey `json:"partitionKey,omitempty"`
+}
+
+// cookieEditorPartitionKey is the partition key of a Firefox cookie.
+type cookieEditorPartitionKey struct {
+	TopLevelSite         string `json:"topLevelSite,omitempty"`
+	HasCrossSiteAncestor bool   `json:"hasCrossSiteAncestor,omitempty"`
+}
+
+// Cookie store IDs of the browser extension API: Chrome's default store is
+// "0", Firefox's stores are named after their container.
+const (
+	chromeDefaultStoreID    = "0"
+	firefoxDefaultStoreID   = "firefox-default"
+	firefoxPrivateStoreID   = "firefox-private"
+	firefoxContainerStoreID = "firefox-container-"
+)
+
+// sameSite values of the browser extension API.
+var cookieEditorSameSite = map[SameSite]string{
+	SameSiteNone:   "no_restriction",
+	SameSiteLax:    "lax",
+	SameSiteStrict: "strict",
+}
+
+// WriteCookieEditorJSON writes cookies to w as JSON in the format of the
+// Cookie-Editor and EditThisCookie browser extensions. Firefox cookies keep
+// their container in their store ID.
+func WriteCookieEditorJSON(w io.Writer, cookies []*Cookie) error {
+	out := make([]cookieEditorCookie, len(cookies))
+	for i, cookie := range cookies {
+		c := cookieEditorCookie{
+			Domain:   cookie.Domain,
+			HostOnly: cookie.HostOnly,
+			HttpOnly: cookie.HttpOnly,
+			Name:     cookie.Name,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			Session:  !cookie.Persistent,
+			StoreID:  cookieEditorStoreID(cookie),
+			Value:    cookie.Value,
+		}
+		if cookie.Persistent && !cookie.Expires.IsZero() {
+			expirationDate := float64(cookie.Expires.Unix()) + float64(cookie.Expires.Nanosecond())/1e9
+			c.ExpirationDate = &expirationDate
+		}
+		if sameSite, ok := cookieEditorSameSite[cookie.SameSite]; ok {
+			c.SameSite = &sameSite
+		} else if cookie.Browser != "firefox" {
+			unspecified := "unspecified"
+			c.SameSite = &unspecified
+		}
+		c.FirstPartyDomain = cookie.OriginAttributes.FirstPartyDomain
+		if cookie.OriginAttributes.PartitionKey != "" {
+			partitionKey, err := parsePartitionKey(cookie.OriginAttributes.PartitionKey)
+			if err != nil {
+				return fmt.Errorf("cookie %q of %s: %v", cookie.Name, cookie.Domain, err)
+			}
+			c.PartitionKey = partitionKey
+		}
+		out[i] = c
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "    ")
+	return encoder.Encode(out)
+}
+
+// ReadCookieEditorJSON reads cookies exported by the Cookie-Editor and
+// EditThisCookie browser extensions from r.
+func ReadCookieEditorJSON(r io.Reader) ([]*Cookie, error) {
+	var in []cookieEditorCookie
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]*Cookie, len(in))
+	for i, c := range in {
+		cookie := &Cookie{
+			Domain:     c.Domain,
+			Name:       c.Name,
+			Path:       c.Path,
+			Value:      c.Value,
+			Secure:     c.Secure,
+			HttpOnly:   c.HttpOnly,
+			HostOnly:   c.HostOnly,
+			Persistent: !c.Session,
+		}
+		if c.ExpirationDate != nil && !c.Session {
+			seconds, frac := math.Modf(*c.ExpirationDate)
+			cookie.Expires = time.Unix(int64(seconds), int64(math.Round(frac*1e6))*1e3)
+		}
+		if c.SameSite != nil {
+			for sameSite, name := range cookieEditorSameSite {
+				if *c.SameSite == name {
+					cookie.SameSite = sameSite
+				}
+			}
+		}
+
+		if err := parseCookieEditorStoreID(c.StoreID, cookie); err != nil {
+			return nil, fmt.Errorf("cookie %d: %v", i, err)
+		}
+		cookie.OriginAttributes.FirstPartyDomain = c.FirstPartyDomain
+		if c.PartitionKey != nil && c.PartitionKey.TopLevelSite != "" {
+			partitionKey, err := c.PartitionKey.firefox()
+			if err != nil {
+				return nil, fmt.Errorf("cookie %d: %v", i, err)
+			}
+			cookie.OriginAttributes.PartitionKey = partitionKey
+		}
+		cookies[i] = cookie
+	}
+
+	return cookies, nil
+}
+
+// cookieEditorStoreID returns the cookie store ID of cookie in the browser
+// extension API.
+func cookieEditorStoreID(cookie *Cookie) string {
+	if cookie.Browser != "firefox" {
+		return chromeDefaultStoreID
+	}
+
+	switch {
+	case cookie.OriginAttributes.PrivateBrowsingID != 0:
+		return firefoxPrivateStoreID
+	case cookie.OriginAttributes.UserContextID != 0:
+		return firefoxContainerStoreID + strconv.Itoa(cookie.OriginAttributes.UserContextID)
+	default:
+		return firefoxDefaultStoreID
+	}
+}
+
+// parseCookieEditorStoreID sets the browser and origin attributes of cookie
+// from a Firefox store ID. Other store IDs are ignored.
+func parseCookieEditorStoreID(storeID string, cookie *Cookie) error {
+	switch {
+	case storeID == firefoxDefaultStoreID:
+		cookie.Browser = "firefox"
+	case storeID == firefoxPrivateStoreID:
+		cookie.Browser = "firefox"
+		cookie.OriginAttributes.PrivateBrowsingID = 1
+	case strings.HasPrefix(storeID, firefoxContainerStoreID):
+		userContextID, err := strconv.Atoi(storeID[len(firefoxContainerStoreID):])
+		if err != nil {
+			return fmt.Errorf("invalid store ID %q", storeID)
+		}
+		cookie.Browser = "firefox"
+		cookie.OriginAttributes.UserContextID = userContextID
+	}
+	return nil
+}
+
+// parsePartitionKey parses a Firefox partition key, which has the form
+// "(scheme,host[,port][,f])": the scheme and host of the top-level site,
+// its port unless it is the scheme's default, and "f" for a cookie with a
+// cross-site ancestor.
+func parsePartitionKey(partitionKey string) (*cookieEditorPartitionKey, error) {
+	if !strings.HasPrefix(partitionKey, "(") || !strings.HasSuffix(partitionKey, ")") {
+		return nil, fmt.Errorf("invalid partition key %q", partitionKey)
+	}
+	fields := strings.Split(partitionKey[1:len(partitionKey)-1], ",")
+	if len(fields) < 2 || len(fields) > 4 || fields[0] == "" || fields[1] == "" {
+		return nil, fmt.Errorf("invalid partition key %q", partitionKey)
+	}
+
+	site := url.URL{Scheme: fields[0], Host: fields[1]}
+	key := &cookieEditorPartitionKey{}
+	for _, field := range fields[2:] {
+		switch {
+		case field == "f" && !key.HasCrossSiteAncestor:
+			key.HasCrossSiteAncestor = true
+		case site.Port() == "" && !key.HasCrossSiteAncestor:
+			if _, err := strconv.ParseUint(field, 10, 16); err != nil {
+				return nil, fmt.Errorf("invalid port in partition key %q", partitionKey)
+			}
+			site.Host += ":" + field
+		default:
+			return nil, fmt.Errorf("invalid partition key %q", partitionKey)
+		}
+	}
+	key.TopLevelSite = site.String()
+	return key, nil
+}
+
+// firefox returns the Firefox partition key of key, the inverse of
+// parsePartitionKey.
+func (key *cookieEditorPartitionKey) firefox() (string, error) {
+	site, err := url.Parse(key.TopLevelSite)
+	if err != nil || site.Scheme == "" || site.Hostname() == "" {
+		return "", fmt.Errorf("invalid top-level site %q", key.TopLevelSite)
+	}
+
+	fields := []string{site.Scheme, site.Hostname()}
+	if site.Port() != "" {
+		fields = append(fields, site.Port())
+	}
+	if key.HasCrossSiteAncestor {
+		fields = append(fields, "f")
+	}
+	return "(" + strings.Join(fields, ",") + ")", nil
+}
@@ -0,0 +1,154 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCookieEditorJSON(t *testing.T) {
+	cookies := formatTestCookies()
+	cookies[0].SameSite = SameSiteStrict
+	cookies = append(cookies, &Cookie{
+		Domain:           ".example.org",
+		Name:             "work",
+		Path:             "/",
+		Value:            "1",
+		Expires:          time.Unix(1893456000, 250000000),
+		Persistent:       true,
+		OriginAttributes: OriginAttributes{UserContextID: 2},
+		Browser:          "firefox",
+	})
+
+	var buf bytes.Buffer
+	if err := WriteCookieEditorJSON(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"expirationDate": 2147483647,`,
+		`"sameSite": "strict"`,
+		`"sameSite": "unspecified"`,
+		`"session": true`,
+		`"storeId": "0"`,
+		`"storeId": "firefox-container-2"`,
+		`"expirationDate": 1893456000.25,`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Want %s in:\n%s", want, buf.String())
+		}
+	}
+
+	read, err := ReadCookieEditorJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, cookies) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v\n%+v", read[0], read[1], read[2])
+	}
+}
+
+func TestWriteCookieEditorJSONSession(t *testing.T) {
+	// A session cookie may still carry a date, which is not exported.
+	cookie := &Cookie{Domain: "example.com", Name: "sid", Path: "/", Expires: time.Unix(1893456000, 0)}
+
+	var buf bytes.Buffer
+	if err := WriteCookieEditorJSON(&buf, []*Cookie{cookie}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"session": true`) || strings.Contains(buf.String(), "expirationDate") {
+		t.Errorf("Want a session cookie without expirationDate; got:\n%s", buf.String())
+	}
+}
+
+func TestCookieEditorPartitionKey(t *testing.T) {
+	tests := []struct {
+		partitionKey string
+		want         string
+	}{
+		{"(https,example.com)", `"topLevelSite": "https://example.com"`},
+		{"(https,example.com,8443)", `"topLevelSite": "https://example.com:8443"`},
+		{"(http,example.com,f)", `"hasCrossSiteAncestor": true`},
+		{"(https,example.com,8443,f)", `"topLevelSite": "https://example.com:8443",`},
+	}
+	for _, test := range tests {
+		cookie := &Cookie{
+			Domain:           "tracker.example",
+			Name:             "id",
+			Path:             "/",
+			HostOnly:         true,
+			OriginAttributes: OriginAttributes{PartitionKey: test.partitionKey},
+			Browser:          "firefox",
+		}
+
+		var buf bytes.Buffer
+		if err := WriteCookieEditorJSON(&buf, []*Cookie{cookie}); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), test.want) {
+			t.Errorf("%s: want %s in:\n%s", test.partitionKey, test.want, buf.String())
+		}
+		read, err := ReadCookieEditorJSON(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := read[0].OriginAttributes.PartitionKey; got != test.partitionKey {
+			t.Errorf("want partition key %s after a round trip; got %s", test.partitionKey, got)
+		}
+	}
+
+	for _, partitionKey := range []string{"https,example.com", "(https)", "(https,example.com,port)", "(https,example.com,f,8443)"} {
+		cookie := &Cookie{Domain: "tracker.example", Name: "id", OriginAttributes: OriginAttributes{PartitionKey: partitionKey}, Browser: "firefox"}
+		if err := WriteCookieEditorJSON(&bytes.Buffer{}, []*Cookie{cookie}); err == nil {
+			t.Errorf("Want an error for partition key %s", partitionKey)
+		}
+	}
+}
+
+func TestReadCookieEditorJSON(t *testing.T) {
+	// As exported by EditThisCookie, which adds an id.
+	export := `[
+{
+    "domain": ".example.com",
+    "expirationDate": 1893456000.123456,
+    "hostOnly": false,
+    "httpOnly": false,
+    "name": "_ga",
+    "path": "/",
+    "sameSite": "no_restriction",
+    "secure": true,
+    "session": false,
+    "storeId": "0",
+    "value": "GA1.2.3",
+    "id": 1
+},
+{
+    "domain": "www.example.com",
+    "hostOnly": true,
+    "httpOnly": true,
+    "name": "sid",
+    "path": "/",
+    "sameSite": null,
+    "secure": false,
+    "session": true,
+    "storeId": "firefox-private",
+    "value": "42",
+    "id": 2
+}
+]`
+
+	cookies, err := ReadCookieEditorJSON(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := cookies[0]; c.SameSite != SameSiteNone || !c.Persistent || c.HostOnly || !c.Expires.Equal(time.Unix(1893456000, 123456000)) {
+		t.Errorf("Want a persistent SameSite=None cookie; got %+v", c)
+	}
+	if c := cookies[1]; c.Persistent || !c.Expires.IsZero() || !c.HostOnly || c.Browser != "firefox" || c.OriginAttributes.PrivateBrowsingID == 0 {
+		t.Errorf("Want a private browsing session cookie; got %+v", c)
+	}
+}
@@ -0,0 +1,37 @@
+package kooky
+
+import "fmt"
+
+// ErrorStage is the step of reading a cookie that failed.
+type ErrorStage string
+
+// Stages at which reading a single cookie can fail.
+const (
+	// StageParse means the cookie's record could not be read from the store.
+	StageParse ErrorStage = "parse"
+	// StageDecrypt means the cookie's value could not be decrypted.
+	StageDecrypt ErrorStage = "decrypt"
+	// StageConvert means a field of the cookie had an unexpected type or value.
+	StageConvert ErrorStage = "convert"
+)
+
+// CookieError describes a single cookie that could not be read.
+type CookieError struct {
+	// RowID identifies the cookie in its store: the sqlite rowid for
+	// Chrome and Firefox, the position in the file for Safari. It is -1
+	// when the failure could not be attributed to a single cookie.
+	RowID  int64
+	Domain string
+	Name   string
+	Stage  ErrorStage
+	Err    error
+}
+
+func (e CookieError) Error() string {
+	return fmt.Sprintf("%s cookie %d (domain:%s, name:%s): %v", e.Stage, e.RowID, e.Domain, e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e CookieError) Unwrap() error {
+	return e.Err
+}
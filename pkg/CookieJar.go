@@ -0,0 +1,281 @@
+package kooky
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// CookieJar is an http.CookieJar populated from a browser cookie store.
+//
+// Cookies received through SetCookies are only kept in memory; the
+// browser's cookie store is never modified.
+type CookieJar struct {
+	mu      sync.Mutex
+	cookies []*Cookie
+}
+
+type jarOptions struct {
+	cookieFile string
+	profile    string
+	filters    []Filter
+}
+
+// JarOption configures how a CookieJar is populated.
+type JarOption func(*jarOptions)
+
+// WithCookieFile reads cookies from filename instead of the browser's
+// default cookie file for the current operating system.
+func WithCookieFile(filename string) JarOption {
+	return func(opts *jarOptions) {
+		opts.cookieFile = filename
+	}
+}
+
+// WithProfile reads cookies from the cookie file of the browser profile
+// with the given ID or display name, as listed by ListProfiles. A cookie
+// file given with WithCookieFile takes precedence.
+func WithProfile(name string) JarOption {
+	return func(opts *jarOptions) {
+		opts.profile = name
+	}
+}
+
+// WithFilters only loads the cookies accepted by every filter into the jar.
+func WithFilters(filters ...Filter) JarOption {
+	return func(opts *jarOptions) {
+		opts.filters = append(opts.filters, filters...)
+	}
+}
+
+// NewCookieJar returns a CookieJar holding the cookies read by reader.
+func NewCookieJar(reader BrowserKookyReader, options ...JarOption) (*CookieJar, error) {
+	opts := jarOptions{}
+	for _, option := range options {
+		option(&opts)
+	}
+
+	switch {
+	case opts.cookieFile != "":
+	case opts.profile != "":
+		cookieFile, err := reader.GetProfileCookieFilePath(runtime.GOOS, opts.profile)
+		if err != nil {
+			return nil, err
+		}
+		opts.cookieFile = cookieFile
+	default:
+		cookieFile, err := reader.GetDefaultCookieFilePath(runtime.GOOS)
+		if err != nil {
+			return nil, err
+		}
+		opts.cookieFile = cookieFile
+	}
+
+	cookies, err := reader.ReadCookies(opts.cookieFile, opts.filters...)
+	if err != nil {
+		return nil, err
+	}
+
+	return newCookieJar(cookies), nil
+}
+
+// NewHTTPClient returns an http.Client whose cookie jar is populated from
+// the browser behind reader, so requests reuse the browser's sessions.
+func NewHTTPClient(reader BrowserKookyReader, options ...JarOption) (*http.Client, error) {
+	jar, err := NewCookieJar(reader, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{Jar: jar}, nil
+}
+
+func newCookieJar(cookies []*Cookie) *CookieJar {
+	jar := &CookieJar{}
+	for _, cookie := range cookies {
+		c := *cookie
+		jar.cookies = append(jar.cookies, &c)
+	}
+
+	return jar
+}
+
+// AllCookies returns a copy of every cookie in the jar.
+func (jar *CookieJar) AllCookies() []*Cookie {
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	cookies := make([]*Cookie, 0, len(jar.cookies))
+	for _, cookie := range jar.cookies {
+		c := *cookie
+		cookies = append(cookies, &c)
+	}
+
+	return cookies
+}
+
+// Cookies implements http.CookieJar, returning the cookies to send in a
+// request to u according to RFC 6265. Like net/http/cookiejar, it returns
+// none for schemes other than http and https.
+func (jar *CookieJar) Cookies(u *url.URL) []*http.Cookie {
+	if !jarScheme(u) {
+		return nil
+	}
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	var cookies []*http.Cookie
+	for _, cookie := range CookiesForURL(u, jar.cookies) {
+		cookies = append(cookies, &http.Cookie{Name: cookie.Name, Value: cookie.Value})
+	}
+
+	return cookies
+}
+
+// SetCookies implements http.CookieJar, storing the cookies received in
+// a response from u according to the storage model of RFC 6265 section 5.3.
+// Like Cookies, it ignores schemes other than http and https.
+func (jar *CookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if !jarScheme(u) {
+		return
+	}
+
+	jar.mu.Lock()
+	defer jar.mu.Unlock()
+
+	now := time.Now()
+	for _, hc := range cookies {
+		cookie, ok := storableCookie(u, hc, now)
+		if !ok {
+			continue
+		}
+
+		expired := !cookie.Expires.IsZero() && !cookie.Expires.After(now)
+		jar.set(cookie, expired)
+	}
+}
+
+// jarScheme reports whether the jar handles the scheme of u.
+func jarScheme(u *url.URL) bool {
+	return u.Scheme == "http" || u.Scheme == "https"
+}
+
+// set replaces the cookie with the same name, domain and path as cookie,
+// or removes it if remove is true.
+func (jar *CookieJar) set(cookie *Cookie, remove bool) {
+	for i, existing := range jar.cookies {
+		if existing.Name != cookie.Name || existing.Path != cookie.Path ||
+			!strings.EqualFold(strings.TrimLeft(existing.Domain, "."), strings.TrimLeft(cookie.Domain, ".")) {
+			continue
+		}
+
+		if remove {
+			jar.cookies = append(jar.cookies[:i], jar.cookies[i+1:]...)
+			return
+		}
+
+		cookie.Creation = existing.Creation
+		jar.cookies[i] = cookie
+		return
+	}
+
+	if !remove {
+		jar.cookies = append(jar.cookies, cookie)
+	}
+}
+
+// storableCookie converts a cookie received from u into a Cookie,
+// reporting false if the cookie must be ignored.
+func storableCookie(u *url.URL, hc *http.Cookie, now time.Time) (*Cookie, bool) {
+	host := canonicalHost(u.Hostname())
+
+	cookie := &Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Path:     hc.Path,
+		Secure:   hc.Secure,
+		HttpOnly: hc.HttpOnly,
+		Creation: now,
+	}
+
+	switch hc.SameSite {
+	case http.SameSiteNoneMode:
+		cookie.SameSite = SameSiteNone
+	case http.SameSiteLaxMode:
+		cookie.SameSite = SameSiteLax
+	case http.SameSiteStrictMode:
+		cookie.SameSite = SameSiteStrict
+	}
+
+	if u.Scheme == "https" {
+		cookie.SourceScheme = SourceSchemeSecure
+		cookie.SourcePort = 443
+	} else {
+		cookie.SourceScheme = SourceSchemeNonSecure
+		cookie.SourcePort = 80
+	}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		cookie.SourcePort = port
+	}
+
+	switch {
+	case hc.MaxAge < 0:
+		cookie.Expires = time.Unix(0, 0)
+	case hc.MaxAge > 0:
+		cookie.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+	default:
+		cookie.Expires = hc.Expires
+	}
+	cookie.Persistent = !cookie.Expires.IsZero()
+
+	domain := canonicalHost(strings.TrimLeft(hc.Domain, "."))
+	if domain != "" && net.ParseIP(host) == nil && isPublicSuffix(domain) {
+		// As in net/http/cookiejar, a public suffix is only accepted as
+		// the domain of a host-only cookie of that very host.
+		if domain != host {
+			return nil, false
+		}
+		domain = ""
+	}
+	switch {
+	case domain == "", domain == host && net.ParseIP(host) != nil:
+		cookie.Domain = host
+		cookie.HostOnly = true
+	case domain == host || (net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)):
+		cookie.Domain = "." + domain
+	default:
+		return nil, false
+	}
+
+	if !strings.HasPrefix(cookie.Path, "/") {
+		cookie.Path = defaultPath(u)
+	}
+
+	return cookie, true
+}
+
+// isPublicSuffix reports whether domain is a public suffix, such as "com"
+// or "co.uk", under which anyone can register names. Single-label domains
+// are treated as public suffixes.
+func isPublicSuffix(domain string) bool {
+	return !strings.Contains(domain, ".") || publicsuffix.List.PublicSuffix(domain) == domain
+}
+
+// defaultPath implements the default-path algorithm of RFC 6265 section 5.1.4.
+func defaultPath(u *url.URL) string {
+	path := requestPath(u)
+	i := strings.LastIndex(path, "/")
+	if i <= 0 {
+		return "/"
+	}
+
+	return path[:i]
+}
@@ -0,0 +1,178 @@
+package kooky
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type fakeReader struct {
+	cookies []*Cookie
+
+	// profiles maps profile IDs to cookie files, which hold the cookies of
+	// files instead of cookies.
+	profiles map[string]string
+	files    map[string][]*Cookie
+}
+
+func (reader fakeReader) ReadCookies(filename string, filters ...Filter) ([]*Cookie, error) {
+	if reader.files != nil {
+		return FilterCookies(reader.files[filename], filters...), nil
+	}
+	return FilterCookies(reader.cookies, filters...), nil
+}
+
+func (reader fakeReader) ReadCookiesLenient(filename string, filters ...Filter) ([]*Cookie, []CookieError, error) {
+	return FilterCookies(reader.cookies, filters...), nil, nil
+}
+
+func (reader fakeReader) ReadAllCookies(filename string) ([]*Cookie, error) {
+	return reader.ReadCookies(filename)
+}
+
+func (reader fakeReader) GetDefaultInstallPath(operatingSystem string) (string, error) {
+	return "", nil
+}
+
+func (reader fakeReader) GetDefaultCookieFilePath(operatingSystem string) (string, error) {
+	return "Cookies", nil
+}
+
+func (reader fakeReader) ListProfiles(operatingSystem string) ([]Profile, error) {
+	return nil, nil
+}
+
+func (reader fakeReader) GetProfileCookieFilePath(operatingSystem string, profile string) (string, error) {
+	if filename, ok := reader.profiles[profile]; ok {
+		return filename, nil
+	}
+	return "", ErrProfileNotFound
+}
+
+func TestCookieJar(t *testing.T) {
+	reader := fakeReader{cookies: []*Cookie{
+		{Domain: ".example.com", Name: "session", Path: "/", Value: "browser"},
+		{Domain: ".other.com", Name: "other", Path: "/", Value: "other"},
+	}}
+
+	jar, err := NewCookieJar(reader, WithFilters(DomainHasSuffix("example.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(jar.AllCookies()); n != 1 {
+		t.Fatalf("got %d cookies in jar, but expected 1", n)
+	}
+
+	u, _ := url.Parse("https://www.example.com/app/login")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "updated", Domain: "example.com", Path: "/"},
+		{Name: "csrf", Value: "token"},
+		{Name: "foreign", Value: "x", Domain: "other.com"},
+	})
+
+	cookies := jar.Cookies(u)
+	got := map[string]string{}
+	for _, cookie := range cookies {
+		got[cookie.Name] = cookie.Value
+	}
+	if len(got) != 2 || got["session"] != "updated" || got["csrf"] != "token" {
+		t.Errorf("unexpected cookies for %s: %v", u, got)
+	}
+
+	for _, scheme := range []string{"ftp", "wss"} {
+		other := &url.URL{Scheme: scheme, Host: u.Host, Path: u.Path}
+		if cookies := jar.Cookies(other); len(cookies) != 0 {
+			t.Errorf("want no cookies for scheme %s; got %v", scheme, cookies)
+		}
+		jar.SetCookies(other, []*http.Cookie{{Name: scheme, Value: "ignored"}})
+		if c := FindCookie("www.example.com", scheme, jar.AllCookies()); c != nil {
+			t.Errorf("want no cookie stored from scheme %s; got %+v", scheme, c)
+		}
+	}
+
+	// csrf is host-only with default path /app
+	other, _ := url.Parse("https://example.com/app")
+	if cookies := jar.Cookies(other); len(cookies) != 1 || cookies[0].Name != "session" {
+		t.Errorf("unexpected cookies for %s: %v", other, cookies)
+	}
+
+	jar.SetCookies(u, []*http.Cookie{{Name: "session", Domain: "example.com", Path: "/", Expires: time.Unix(1, 0)}})
+	for _, cookie := range jar.Cookies(u) {
+		if cookie.Name == "session" {
+			t.Errorf("expired cookie %q was not removed", cookie.Name)
+		}
+	}
+
+	if n := len(reader.cookies); n != 2 || reader.cookies[0].Value != "browser" {
+		t.Error("SetCookies modified the source cookies")
+	}
+}
+
+func TestCookieJarProfile(t *testing.T) {
+	reader := fakeReader{
+		profiles: map[string]string{"Profile 1": "Profile 1/Cookies"},
+		files: map[string][]*Cookie{
+			"Cookies":           {{Domain: ".example.com", Name: "default", Path: "/"}},
+			"Profile 1/Cookies": {{Domain: ".example.com", Name: "work", Path: "/"}},
+		},
+	}
+
+	jar, err := NewCookieJar(reader, WithProfile("Profile 1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cookies := jar.AllCookies(); len(cookies) != 1 || cookies[0].Name != "work" {
+		t.Errorf("want the cookie of Profile 1; got %v", cookies)
+	}
+
+	if _, err := NewCookieJar(reader, WithProfile("Missing")); err != ErrProfileNotFound {
+		t.Errorf("want %v for an unknown profile; got %v", ErrProfileNotFound, err)
+	}
+}
+
+func TestCookieJarPublicSuffix(t *testing.T) {
+	jar := newCookieJar(nil)
+
+	u, _ := url.Parse("http://www.example.com/")
+	jar.SetCookies(u, []*http.Cookie{
+		{Name: "super", Value: "x", Domain: "com"},
+		{Name: "super-uk", Value: "x", Domain: "co.uk"},
+		{Name: "parent", Value: "x", Domain: "example.com"},
+	})
+	for _, target := range []string{"https://evil.com/", "https://evil.co.uk/"} {
+		u, _ := url.Parse(target)
+		if cookies := jar.Cookies(u); len(cookies) != 0 {
+			t.Errorf("want no cookies for %s; got %v", target, cookies)
+		}
+	}
+	if n := len(jar.AllCookies()); n != 1 {
+		t.Errorf("got %d cookies in jar, but expected 1", n)
+	}
+
+	// A host that is itself a public suffix may set a host-only cookie.
+	host, _ := url.Parse("http://localhost/")
+	jar.SetCookies(host, []*http.Cookie{{Name: "local", Value: "x", Domain: "localhost"}})
+	if cookies := jar.Cookies(host); len(cookies) != 1 || cookies[0].Name != "local" {
+		t.Errorf("want host-only cookie for %s; got %v", host, cookies)
+	}
+	sub, _ := url.Parse("http://sub.localhost/")
+	if cookies := jar.Cookies(sub); len(cookies) != 0 {
+		t.Errorf("want no cookies for %s; got %v", sub, cookies)
+	}
+}
+
+func TestCookieJarReplaceHostOnly(t *testing.T) {
+	jar := newCookieJar(nil)
+
+	// As in RFC 6265 section 5.3, step 11, a domain cookie replaces the
+	// host-only cookie with the same name, domain and path.
+	u, _ := url.Parse("http://example.com/")
+	jar.SetCookies(u, []*http.Cookie{{Name: "id", Value: "host", Path: "/"}})
+	jar.SetCookies(u, []*http.Cookie{{Name: "id", Value: "domain", Domain: "example.com", Path: "/"}})
+
+	cookies := jar.AllCookies()
+	if len(cookies) != 1 || cookies[0].Value != "domain" || cookies[0].HostOnly {
+		t.Errorf("want only the domain cookie; got %v", cookies)
+	}
+}
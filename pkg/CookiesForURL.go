@@ -0,0 +1,121 @@
+package kooky
+
+import (
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CookiesForURL returns the cookies a browser would send with a request to u,
+// following the rules of RFC 6265 section 5.4:
+//
+//   - HostOnly cookies are only sent to that exact host; other cookies are
+//     sent to the domain and all of its subdomains
+//   - the cookie path must path-match the request path
+//   - Secure cookies are only sent over https and wss
+//   - expired cookies are never sent
+//
+// The result is sorted as the spec recommends: cookies with longer paths
+// first, then cookies with earlier creation times.
+func CookiesForURL(u *url.URL, cookies []*Cookie) []*Cookie {
+	return cookiesForURL(u, cookies, time.Now())
+}
+
+// CookieHeader returns the value of the Cookie header a browser would send
+// with a request to u, or "" if no cookie applies.
+func CookieHeader(u *url.URL, cookies []*Cookie) string {
+	var pairs []string
+	for _, cookie := range CookiesForURL(u, cookies) {
+		pairs = append(pairs, cookie.Name+"="+cookie.Value)
+	}
+
+	return strings.Join(pairs, "; ")
+}
+
+func cookiesForURL(u *url.URL, cookies []*Cookie, now time.Time) []*Cookie {
+	host := canonicalHost(u.Hostname())
+	path := requestPath(u)
+	secure := u.Scheme == "https" || u.Scheme == "wss"
+
+	var matches []*Cookie
+	for _, cookie := range cookies {
+		if cookie.Secure && !secure {
+			continue
+		}
+		if !cookie.Expires.IsZero() && !cookie.Expires.After(now) {
+			continue
+		}
+		if !domainMatch(host, cookie) {
+			continue
+		}
+		if !pathMatch(path, cookiePath(cookie)) {
+			continue
+		}
+
+		matches = append(matches, cookie)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		pi, pj := cookiePath(matches[i]), cookiePath(matches[j])
+		if len(pi) != len(pj) {
+			return len(pi) > len(pj)
+		}
+		return matches[i].Creation.Before(matches[j].Creation)
+	})
+
+	return matches
+}
+
+func canonicalHost(host string) string {
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// domainMatch implements the domain-matching and host-only rules of
+// RFC 6265 sections 5.1.3 and 5.4.
+func domainMatch(host string, cookie *Cookie) bool {
+	domain := canonicalHost(strings.TrimLeft(cookie.Domain, "."))
+	if domain == "" {
+		return false
+	}
+
+	if host == domain {
+		return true
+	}
+	if cookie.HostOnly || net.ParseIP(host) != nil {
+		return false
+	}
+
+	return strings.HasSuffix(host, "."+domain)
+}
+
+// requestPath returns the path of the request URI, defaulting to "/".
+func requestPath(u *url.URL) string {
+	path := u.EscapedPath()
+	if !strings.HasPrefix(path, "/") {
+		return "/"
+	}
+
+	return path
+}
+
+func cookiePath(cookie *Cookie) string {
+	if !strings.HasPrefix(cookie.Path, "/") {
+		return "/"
+	}
+
+	return cookie.Path
+}
+
+// pathMatch implements the path-matching rule of RFC 6265 section 5.1.4.
+func pathMatch(requestPath string, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
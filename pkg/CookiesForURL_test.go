@@ -0,0 +1,62 @@
+package kooky
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestCookiesForURL(t *testing.T) {
+	now := time.Now()
+	cookies := []*Cookie{
+		{Domain: ".example.com", Name: "domain", Path: "/", Value: "1", Creation: now.Add(-3 * time.Hour)},
+		{Domain: "example.com", HostOnly: true, Name: "hostonly", Path: "/", Value: "2", Creation: now.Add(-2 * time.Hour)},
+		{Domain: ".example.com", Name: "secure", Path: "/", Value: "3", Secure: true, Creation: now.Add(-time.Hour)},
+		{Domain: ".example.com", Name: "deep", Path: "/docs", Value: "4", Creation: now},
+		{Domain: ".example.com", Name: "expired", Path: "/", Value: "5", Expires: now.Add(-time.Hour)},
+		{Domain: ".other.com", Name: "other", Path: "/", Value: "6"},
+		{Domain: "www.example.com", HostOnly: true, Name: "www", Path: "/", Value: "7", Creation: now.Add(-4 * time.Hour)},
+	}
+
+	tests := []struct {
+		url  string
+		want string
+	}{
+		{"https://example.com/docs/index.html", "deep=4; domain=1; hostonly=2; secure=3"},
+		{"http://example.com/", "domain=1; hostonly=2"},
+		{"http://www.example.com/", "www=7; domain=1"},
+		{"https://www.example.com/docsearch", "www=7; domain=1; secure=3"},
+		{"http://badexample.com/", ""},
+	}
+
+	for _, test := range tests {
+		u, err := url.Parse(test.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := CookieHeader(u, cookies); got != test.want {
+			t.Errorf("%s: want %q; got %q", test.url, test.want, got)
+		}
+	}
+}
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		requestPath string
+		cookiePath  string
+		want        bool
+	}{
+		{"/", "/", true},
+		{"/docs", "/docs", true},
+		{"/docs/", "/docs", true},
+		{"/docs/a", "/docs/", true},
+		{"/docsearch", "/docs", false},
+		{"/", "/docs", false},
+	}
+
+	for _, test := range tests {
+		if got := pathMatch(test.requestPath, test.cookiePath); got != test.want {
+			t.Errorf("pathMatch(%q, %q): want %v; got %v", test.requestPath, test.cookiePath, test.want, got)
+		}
+	}
+}
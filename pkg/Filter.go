@@ -0,0 +1,147 @@
+package kooky
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Filter reports whether a cookie should be included in the results of a read.
+//
+// Readers may evaluate filters before a cookie's Value has been decrypted,
+// so filters should not depend on the Value field.
+type Filter func(*Cookie) bool
+
+// FilterCookie reports whether the cookie is accepted by every filter.
+func FilterCookie(cookie *Cookie, filters ...Filter) bool {
+	for _, filter := range filters {
+		if filter != nil && !filter(cookie) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// FilterCookies returns the cookies accepted by every filter.
+func FilterCookies(cookies []*Cookie, filters ...Filter) []*Cookie {
+	var filtered []*Cookie
+	for _, cookie := range cookies {
+		if FilterCookie(cookie, filters...) {
+			filtered = append(filtered, cookie)
+		}
+	}
+
+	return filtered
+}
+
+// And returns a Filter accepting cookies accepted by all of the input filters.
+// Nil filters are skipped, so And() accepts every cookie.
+func And(filters ...Filter) Filter {
+	return func(cookie *Cookie) bool {
+		return FilterCookie(cookie, filters...)
+	}
+}
+
+// Or returns a Filter accepting cookies accepted by any of the input filters.
+// Nil filters are skipped, so Or() accepts none.
+func Or(filters ...Filter) Filter {
+	return func(cookie *Cookie) bool {
+		for _, filter := range filters {
+			if filter != nil && filter(cookie) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not returns a Filter accepting cookies rejected by the input filter. A nil
+// filter is taken as accepting every cookie, as by FilterCookie, so Not(nil)
+// accepts none.
+func Not(filter Filter) Filter {
+	return func(cookie *Cookie) bool {
+		return !FilterCookie(cookie, filter)
+	}
+}
+
+// Domain returns a Filter accepting cookies whose domain is exactly domain.
+func Domain(domain string) Filter {
+	return func(cookie *Cookie) bool {
+		return cookie.Domain == domain
+	}
+}
+
+// DomainHasSuffix returns a Filter accepting cookies set for suffix or any
+// of its subdomains. Leading dots are ignored on both sides, so
+// "example.com" matches "example.com", ".example.com" and
+// "www.example.com", but not "badexample.com".
+func DomainHasSuffix(suffix string) Filter {
+	suffix = strings.ToLower(strings.TrimLeft(suffix, "."))
+	return func(cookie *Cookie) bool {
+		domain := strings.ToLower(strings.TrimLeft(cookie.Domain, "."))
+		return domain == suffix || strings.HasSuffix(domain, "."+suffix)
+	}
+}
+
+// Name returns a Filter accepting cookies whose name is exactly name.
+func Name(name string) Filter {
+	return func(cookie *Cookie) bool {
+		return cookie.Name == name
+	}
+}
+
+// NameMatches returns a Filter accepting cookies whose name matches the
+// regular expression.
+func NameMatches(re *regexp.Regexp) Filter {
+	return func(cookie *Cookie) bool {
+		return re.MatchString(cookie.Name)
+	}
+}
+
+// PathHasPrefix returns a Filter accepting cookies whose path starts with prefix.
+func PathHasPrefix(prefix string) Filter {
+	return func(cookie *Cookie) bool {
+		return strings.HasPrefix(cookie.Path, prefix)
+	}
+}
+
+// Container returns a Filter accepting cookies of the named Firefox container.
+// Use Container("") for cookies outside of any container.
+func Container(name string) Filter {
+	return func(cookie *Cookie) bool {
+		return cookie.Container == name && (name != "" || cookie.OriginAttributes.UserContextID == 0)
+	}
+}
+
+// Secure is a Filter accepting only cookies with the Secure attribute.
+func Secure(cookie *Cookie) bool {
+	return cookie.Secure
+}
+
+// HttpOnly is a Filter accepting only cookies with the HttpOnly attribute.
+func HttpOnly(cookie *Cookie) bool {
+	return cookie.HttpOnly
+}
+
+// Session is a Filter accepting only session cookies, i.e. cookies that are
+// not kept across browser restarts.
+func Session(cookie *Cookie) bool {
+	return !cookie.Persistent
+}
+
+// ExpiresBefore returns a Filter accepting cookies that expire before t.
+// Cookies without an expiry never match.
+func ExpiresBefore(t time.Time) Filter {
+	return func(cookie *Cookie) bool {
+		return !cookie.Expires.IsZero() && cookie.Expires.Before(t)
+	}
+}
+
+// ExpiresAfter returns a Filter accepting cookies that do not expire before t.
+// Cookies without an expiry always match.
+func ExpiresAfter(t time.Time) Filter {
+	return func(cookie *Cookie) bool {
+		return cookie.Expires.IsZero() || !cookie.Expires.Before(t)
+	}
+}
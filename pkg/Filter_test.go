@@ -0,0 +1,82 @@
+package kooky
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestFilters(t *testing.T) {
+	now := time.Now()
+	cookie := &Cookie{
+		Domain:     ".example.com",
+		Name:       "session_id",
+		Path:       "/account/",
+		Expires:    now.Add(time.Hour),
+		Persistent: true,
+		Secure:     true,
+		HttpOnly:   false,
+	}
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"Domain", Domain(".example.com"), true},
+		{"Domain mismatch", Domain("example.com"), false},
+		{"DomainHasSuffix", DomainHasSuffix("example.com"), true},
+		{"DomainHasSuffix parent", DomainHasSuffix("com"), true},
+		{"DomainHasSuffix partial label", DomainHasSuffix("ample.com"), false},
+		{"Name", Name("session_id"), true},
+		{"NameMatches", NameMatches(regexp.MustCompile(`^sess`)), true},
+		{"NameMatches mismatch", NameMatches(regexp.MustCompile(`^id`)), false},
+		{"PathHasPrefix", PathHasPrefix("/account"), true},
+		{"PathHasPrefix mismatch", PathHasPrefix("/admin"), false},
+		{"Secure", Secure, true},
+		{"HttpOnly", HttpOnly, false},
+		{"Session", Session, false},
+		{"ExpiresBefore", ExpiresBefore(now.Add(2 * time.Hour)), true},
+		{"ExpiresAfter", ExpiresAfter(now.Add(2 * time.Hour)), false},
+		{"And", And(Secure, Name("session_id")), true},
+		{"And mismatch", And(Secure, HttpOnly), false},
+		{"Or", Or(HttpOnly, Secure), true},
+		{"Or mismatch", Or(HttpOnly, Session), false},
+		{"Not", Not(HttpOnly), true},
+		{"Not nil", Not(nil), false},
+		{"And nil", And(nil, Secure), true},
+		{"Or nil", Or(nil, HttpOnly), false},
+		{"Or Not nil", Or(Not(nil), HttpOnly), false},
+		{"Or Not nil match", Or(Not(nil), Secure), true},
+		{"Container none", Container(""), true},
+		{"Container mismatch", Container("Work"), false},
+	}
+
+	for _, test := range tests {
+		if got := test.filter(cookie); got != test.want {
+			t.Errorf("%s: want %v; got %v", test.name, test.want, got)
+		}
+	}
+}
+
+func TestSessionCookiesExpireAfter(t *testing.T) {
+	session := &Cookie{Name: "session"}
+	if !ExpiresAfter(time.Now())(session) {
+		t.Error("ExpiresAfter should accept session cookies")
+	}
+	if ExpiresBefore(time.Now())(session) {
+		t.Error("ExpiresBefore should reject session cookies")
+	}
+	if !Session(session) {
+		t.Error("Session should accept session cookies")
+	}
+	// A cookie that is not persistent is a session cookie even with a date,
+	// by which the expiry filters still go.
+	dated := &Cookie{Name: "session", Expires: time.Now().Add(-time.Hour)}
+	if !Session(dated) {
+		t.Error("Session should accept cookies that are not persistent")
+	}
+	if !ExpiresBefore(time.Now())(dated) || ExpiresAfter(time.Now())(dated) {
+		t.Error("ExpiresBefore and ExpiresAfter should go by the expiry of cookies that are not persistent")
+	}
+}
@@ -0,0 +1,248 @@
+package kooky
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// JSONVersion is the version of the kooky JSON format written by WriteJSON.
+//
+// Version 1 is an object holding the version and an array of cookies:
+//
+//	{
+//	  "version": 1,
+//	  "cookies": [
+//	    {
+//	      "domain": ".example.com",
+//	      "name": "session",
+//	      "path": "/",
+//	      "value": "abc123",
+//	      "expires": "2030-01-01T00:00:00Z",   // RFC 3339, omitted if unset
+//	      "creation": "...",                   // RFC 3339, omitted if unset
+//	      "lastAccess": "...",                 // RFC 3339, omitted if unset
+//	      "secure": true,
+//	      "httpOnly": true,
+//	      "hostOnly": false,
+//	      "persistent": true,
+//	      "sameSite": "Lax",        // Unspecified, None, Lax or Strict
+//	      "priority": "Medium",     // Unspecified, Low, Medium or High
+//	      "sourceScheme": "Secure", // Unset, NonSecure or Secure
+//	      "sourcePort": 443,        // omitted if unknown
+//	      "originAttributes": {     // omitted if empty
+//	        "userContextId": 2, "privateBrowsingId": 0,
+//	        "firstPartyDomain": "", "partitionKey": ""
+//	      },
+//	      "container": "Work",
+//	      "comment": "", "commentUrl": "", "ports": [80],
+//	      "browser": "firefox", "profile": "default", "file": "/path/to/cookies.sqlite",
+//	      "source": "sessionstore"  // omitted for the browser's main store
+//	    }
+//	  ]
+//	}
+//
+// Empty strings, false booleans and empty lists may be omitted. Readers
+// ignore fields they do not know; fields are only ever added within a
+// version, and changes to existing ones increment it.
+const JSONVersion = 1
+
+type jsonFile struct {
+	Version int          `json:"version"`
+	Cookies []jsonCookie `json:"cookies"`
+}
+
+type jsonCookie struct {
+	Domain string `json:"domain"`
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Value  string `json:"value"`
+
+	Expires    *time.Time `json:"expires,omitempty"`
+	Creation   *time.Time `json:"creation,omitempty"`
+	LastAccess *time.Time `json:"lastAccess,omitempty"`
+
+	Secure     bool `json:"secure,omitempty"`
+	HttpOnly   bool `json:"httpOnly,omitempty"`
+	HostOnly   bool `json:"hostOnly,omitempty"`
+	Persistent bool `json:"persistent,omitempty"`
+
+	SameSite     string `json:"sameSite,omitempty"`
+	Priority     string `json:"priority,omitempty"`
+	SourceScheme string `json:"sourceScheme,omitempty"`
+	SourcePort   int    `json:"sourcePort,omitempty"`
+
+	OriginAttributes *jsonOriginAttributes `json:"originAttributes,omitempty"`
+	Container        string                `json:"container,omitempty"`
+
+	Comment    string `json:"comment,omitempty"`
+	CommentURL string `json:"commentUrl,omitempty"`
+	Ports      []int  `json:"ports,omitempty"`
+
+	Browser string `json:"browser,omitempty"`
+	Profile string `json:"profile,omitempty"`
+	File    string `json:"file,omitempty"`
+	Source  string `json:"source,omitempty"`
+}
+
+type jsonOriginAttributes struct {
+	UserContextID     int    `json:"userContextId,omitempty"`
+	PrivateBrowsingID int    `json:"privateBrowsingId,omitempty"`
+	FirstPartyDomain  string `json:"firstPartyDomain,omitempty"`
+	PartitionKey      string `json:"partitionKey,omitempty"`
+}
+
+// WriteJSON writes cookies to w in the kooky JSON format, described at
+// JSONVersion.
+func WriteJSON(w io.Writer, cookies []*Cookie) error {
+	file := jsonFile{Version: JSONVersion, Cookies: make([]jsonCookie, len(cookies))}
+	for i, cookie := range cookies {
+		c := jsonCookie{
+			Domain:     cookie.Domain,
+			Name:       cookie.Name,
+			Path:       cookie.Path,
+			Value:      cookie.Value,
+			Expires:    jsonTime(cookie.Expires),
+			Creation:   jsonTime(cookie.Creation),
+			LastAccess: jsonTime(cookie.LastAccess),
+			Secure:     cookie.Secure,
+			HttpOnly:   cookie.HttpOnly,
+			HostOnly:   cookie.HostOnly,
+			Persistent: cookie.Persistent,
+			SourcePort: cookie.SourcePort,
+			Container:  cookie.Container,
+			Comment:    cookie.Comment,
+			CommentURL: cookie.CommentURL,
+			Ports:      cookie.Ports,
+			Browser:    cookie.Browser,
+			Profile:    cookie.Profile,
+			File:       cookie.File,
+			Source:     cookie.Source,
+		}
+		if cookie.SameSite != SameSiteUnspecified {
+			c.SameSite = cookie.SameSite.String()
+		}
+		if cookie.Priority != PriorityUnspecified {
+			c.Priority = cookie.Priority.String()
+		}
+		if cookie.SourceScheme != SourceSchemeUnset {
+			c.SourceScheme = cookie.SourceScheme.String()
+		}
+		if cookie.OriginAttributes != (OriginAttributes{}) {
+			c.OriginAttributes = &jsonOriginAttributes{
+				UserContextID:     cookie.OriginAttributes.UserContextID,
+				PrivateBrowsingID: cookie.OriginAttributes.PrivateBrowsingID,
+				FirstPartyDomain:  cookie.OriginAttributes.FirstPartyDomain,
+				PartitionKey:      cookie.OriginAttributes.PartitionKey,
+			}
+		}
+		file.Cookies[i] = c
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(file)
+}
+
+// ReadJSON reads cookies in the kooky JSON format from r. It fails on
+// versions newer than JSONVersion.
+func ReadJSON(r io.Reader) ([]*Cookie, error) {
+	var file jsonFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, err
+	}
+	if file.Version < 1 || file.Version > JSONVersion {
+		return nil, fmt.Errorf("unsupported kooky JSON version %d", file.Version)
+	}
+
+	cookies := make([]*Cookie, len(file.Cookies))
+	for i, c := range file.Cookies {
+		cookie := &Cookie{
+			Domain:     c.Domain,
+			Name:       c.Name,
+			Path:       c.Path,
+			Value:      c.Value,
+			Secure:     c.Secure,
+			HttpOnly:   c.HttpOnly,
+			HostOnly:   c.HostOnly,
+			Persistent: c.Persistent,
+			SourcePort: c.SourcePort,
+			Container:  c.Container,
+			Comment:    c.Comment,
+			CommentURL: c.CommentURL,
+			Ports:      c.Ports,
+			Browser:    c.Browser,
+			Profile:    c.Profile,
+			File:       c.File,
+			Source:     c.Source,
+		}
+		if c.Expires != nil {
+			cookie.Expires = *c.Expires
+		}
+		if c.Creation != nil {
+			cookie.Creation = *c.Creation
+		}
+		if c.LastAccess != nil {
+			cookie.LastAccess = *c.LastAccess
+		}
+
+		var ok bool
+		if cookie.SameSite, ok = parseSameSite(c.SameSite); !ok {
+			return nil, fmt.Errorf("cookie %d: invalid sameSite %q", i, c.SameSite)
+		}
+		if cookie.Priority, ok = parsePriority(c.Priority); !ok {
+			return nil, fmt.Errorf("cookie %d: invalid priority %q", i, c.Priority)
+		}
+		if cookie.SourceScheme, ok = parseSourceScheme(c.SourceScheme); !ok {
+			return nil, fmt.Errorf("cookie %d: invalid sourceScheme %q", i, c.SourceScheme)
+		}
+
+		if c.OriginAttributes != nil {
+			cookie.OriginAttributes = OriginAttributes{
+				UserContextID:     c.OriginAttributes.UserContextID,
+				PrivateBrowsingID: c.OriginAttributes.PrivateBrowsingID,
+				FirstPartyDomain:  c.OriginAttributes.FirstPartyDomain,
+				PartitionKey:      c.OriginAttributes.PartitionKey,
+			}
+		}
+		cookies[i] = cookie
+	}
+
+	return cookies, nil
+}
+
+// jsonTime returns t for encoding, or nil for the zero time.
+func jsonTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func parseSameSite(s string) (SameSite, bool) {
+	for _, sameSite := range []SameSite{SameSiteUnspecified, SameSiteNone, SameSiteLax, SameSiteStrict} {
+		if s == "" || strings.EqualFold(s, sameSite.String()) {
+			return sameSite, true
+		}
+	}
+	return SameSiteUnspecified, false
+}
+
+func parsePriority(s string) (Priority, bool) {
+	for _, priority := range []Priority{PriorityUnspecified, PriorityLow, PriorityMedium, PriorityHigh} {
+		if s == "" || strings.EqualFold(s, priority.String()) {
+			return priority, true
+		}
+	}
+	return PriorityUnspecified, false
+}
+
+func parseSourceScheme(s string) (SourceScheme, bool) {
+	for _, scheme := range []SourceScheme{SourceSchemeUnset, SourceSchemeNonSecure, SourceSchemeSecure} {
+		if s == "" || strings.EqualFold(s, scheme.String()) {
+			return scheme, true
+		}
+	}
+	return SourceSchemeUnset, false
+}
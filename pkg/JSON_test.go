@@ -0,0 +1,72 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSON(t *testing.T) {
+	cookies := []*Cookie{{
+		Domain:       ".example.com",
+		Name:         "session",
+		Path:         "/",
+		Value:        "abc123",
+		Expires:      time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Creation:     time.Date(2020, 6, 1, 12, 0, 0, 500, time.UTC),
+		LastAccess:   time.Date(2020, 6, 2, 8, 0, 0, 0, time.UTC),
+		Secure:       true,
+		HttpOnly:     true,
+		Persistent:   true,
+		SameSite:     SameSiteLax,
+		Priority:     PriorityHigh,
+		SourceScheme: SourceSchemeSecure,
+		SourcePort:   8443,
+		OriginAttributes: OriginAttributes{
+			UserContextID:    2,
+			FirstPartyDomain: "example.com",
+		},
+		Container: "Work",
+		Browser:   "firefox",
+		Profile:   "default",
+		File:      "/home/jane/.mozilla/firefox/default/cookies.sqlite",
+		Source:    "sessionstore",
+	}, {
+		Domain:     "www.example.com",
+		Name:       "prefs",
+		Path:       "/settings",
+		Value:      "dark",
+		HostOnly:   true,
+		Comment:    "a comment",
+		CommentURL: "https://example.com/cookies",
+		Ports:      []int{80, 8080},
+		Browser:    "safari",
+	}}
+
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"version": 1`, `"sameSite": "Lax"`, `"userContextId": 2`, `"expires": "2030-01-01T00:00:00Z"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Want %s in:\n%s", want, buf.String())
+		}
+	}
+
+	read, err := ReadJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, cookies) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v", read[0], read[1])
+	}
+
+	if _, err := ReadJSON(strings.NewReader(`{"version": 2, "cookies": []}`)); err == nil {
+		t.Error("Want an error for a future version")
+	}
+	if _, err := ReadJSON(strings.NewReader(`{"version": 1, "cookies": [{"name": "a", "sameSite": "sometimes"}]}`)); err == nil {
+		t.Error("Want an error for an invalid sameSite")
+	}
+}
@@ -0,0 +1,275 @@
+package kooky
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// The LWP format is libwww-perl's, read and written by Python's
+// http.cookiejar.LWPCookieJar. Each cookie is a Set-Cookie3 line of
+// attributes, quoted unless they are a single word:
+//
+//	Set-Cookie3: name=value; path="/"; domain=".example.com"; path_spec; domain_dot; secure; expires="2030-01-01 00:00:00Z"; HttpOnly=None; version=0
+
+const (
+	lwpHeader     = "#LWP-Cookies-2.0"
+	lwpPrefix     = "Set-Cookie3:"
+	lwpTimeLayout = "2006-01-02 15:04:05Z"
+)
+
+type lwpAttribute struct {
+	key, value string
+	hasValue   bool
+}
+
+// WriteLWPCookies writes cookies to w in the LWP Set-Cookie3 format.
+// Cookies without an expiry are written as session cookies, to be
+// discarded.
+func WriteLWPCookies(w io.Writer, cookies []*Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, lwpHeader)
+
+	for _, cookie := range cookies {
+		if strings.ContainsAny(cookie.Name, "=;, \t\r\n") || cookie.Name == "" {
+			return fmt.Errorf("cookie %q of %s: name cannot be written", cookie.Name, cookie.Domain)
+		}
+
+		domain := cookieDomain(cookie)
+		attributes := []lwpAttribute{
+			{cookie.Name, cookie.Value, true},
+			{"path", cookie.Path, true},
+			{"domain", domain, true},
+		}
+		if len(cookie.Ports) > 0 {
+			ports := make([]string, len(cookie.Ports))
+			for i, port := range cookie.Ports {
+				ports[i] = strconv.Itoa(port)
+			}
+			attributes = append(attributes, lwpAttribute{"port", strings.Join(ports, ","), true})
+		}
+		attributes = append(attributes, lwpAttribute{key: "path_spec"})
+		if len(cookie.Ports) > 0 {
+			attributes = append(attributes, lwpAttribute{key: "port_spec"})
+		}
+		if strings.HasPrefix(domain, ".") {
+			attributes = append(attributes, lwpAttribute{key: "domain_dot"})
+		}
+		if cookie.Secure {
+			attributes = append(attributes, lwpAttribute{key: "secure"})
+		}
+		if cookie.Expires.IsZero() {
+			attributes = append(attributes, lwpAttribute{key: "discard"})
+		} else {
+			attributes = append(attributes, lwpAttribute{"expires", cookie.Expires.UTC().Format(lwpTimeLayout), true})
+		}
+		if cookie.Comment != "" {
+			attributes = append(attributes, lwpAttribute{"comment", cookie.Comment, true})
+		}
+		if cookie.CommentURL != "" {
+			attributes = append(attributes, lwpAttribute{"commenturl", cookie.CommentURL, true})
+		}
+		if cookie.HttpOnly {
+			// Python keeps unknown attributes, with None as their value.
+			attributes = append(attributes, lwpAttribute{"HttpOnly", "None", true})
+		}
+		attributes = append(attributes, lwpAttribute{"version", "0", true})
+
+		fmt.Fprintln(bw, lwpPrefix, joinLWPAttributes(attributes))
+	}
+
+	return bw.Flush()
+}
+
+// ReadLWPCookies reads cookies in the LWP Set-Cookie3 format from r.
+func ReadLWPCookies(r io.Reader) ([]*Cookie, error) {
+	var cookies []*Cookie
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(line, lwpPrefix) {
+			continue
+		}
+
+		headers, err := splitLWPHeaders(line[len(lwpPrefix):])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		for _, attributes := range headers {
+			cookie, err := lwpCookie(attributes)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+			}
+			cookies = append(cookies, cookie)
+		}
+	}
+
+	return cookies, scanner.Err()
+}
+
+// lwpCookie returns the cookie of the attributes of a Set-Cookie3 header,
+// the first of which is its name and value.
+func lwpCookie(attributes []lwpAttribute) (*Cookie, error) {
+	if len(attributes) == 0 || !attributes[0].hasValue {
+		return nil, fmt.Errorf("expected name=value")
+	}
+
+	cookie := &Cookie{Name: attributes[0].key, Value: attributes[0].value, Persistent: true}
+	for _, attribute := range attributes[1:] {
+		switch strings.ToLower(attribute.key) {
+		case "path":
+			cookie.Path = attribute.value
+		case "domain":
+			cookie.Domain = attribute.value
+		case "port":
+			for _, port := range strings.Split(attribute.value, ",") {
+				p, err := strconv.Atoi(strings.TrimSpace(port))
+				if err != nil {
+					return nil, fmt.Errorf("invalid port %q", port)
+				}
+				cookie.Ports = append(cookie.Ports, p)
+			}
+		case "secure":
+			cookie.Secure = true
+		case "discard":
+			cookie.Persistent = false
+		case "expires":
+			expires, err := time.Parse(lwpTimeLayout, attribute.value)
+			if err != nil {
+				if expires, err = time.Parse(time.RFC3339, attribute.value); err != nil {
+					return nil, fmt.Errorf("invalid expiry %q", attribute.value)
+				}
+			}
+			cookie.Expires = expires
+		case "comment":
+			cookie.Comment = attribute.value
+		case "commenturl":
+			cookie.CommentURL = attribute.value
+		case "httponly":
+			cookie.HttpOnly = true
+		}
+	}
+	cookie.HostOnly = !strings.HasPrefix(cookie.Domain, ".")
+
+	return cookie, nil
+}
+
+// joinLWPAttributes joins attributes like Python's
+// http.cookiejar.join_header_words.
+func joinLWPAttributes(attributes []lwpAttribute) string {
+	parts := make([]string, len(attributes))
+	for i, attribute := range attributes {
+		if !attribute.hasValue {
+			parts[i] = attribute.key
+			continue
+		}
+		value := attribute.value
+		if !isLWPWord(value) {
+			value = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+		}
+		parts[i] = attribute.key + "=" + value
+	}
+	return strings.Join(parts, "; ")
+}
+
+// isLWPWord reports whether s need not be quoted: whether it matches the
+// regular expression ^\w+$.
+func isLWPWord(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitLWPHeaders splits the attributes of a header value like Python's
+// http.cookiejar.split_header_words: attributes are separated by
+// semicolons and headers by commas outside of quotes.
+func splitLWPHeaders(s string) ([][]lwpAttribute, error) {
+	var headers [][]lwpAttribute
+	var attributes []lwpAttribute
+
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			break
+		}
+
+		switch s[0] {
+		case ',':
+			if len(attributes) > 0 {
+				headers = append(headers, attributes)
+				attributes = nil
+			}
+			s = s[1:]
+			continue
+		case ';':
+			s = s[1:]
+			continue
+		}
+
+		end := strings.IndexAny(s, "= \t;,")
+		if end < 0 {
+			end = len(s)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("unexpected %q", s[0])
+		}
+		attribute := lwpAttribute{key: s[:end]}
+		s = strings.TrimLeft(s[end:], " \t")
+
+		if strings.HasPrefix(s, "=") {
+			s = strings.TrimLeft(s[1:], " \t")
+			attribute.hasValue = true
+			if strings.HasPrefix(s, `"`) {
+				value, rest, err := unquoteLWP(s)
+				if err != nil {
+					return nil, err
+				}
+				attribute.value, s = value, rest
+			} else {
+				end := strings.IndexAny(s, " \t;,")
+				if end < 0 {
+					end = len(s)
+				}
+				attribute.value, s = s[:end], s[end:]
+			}
+		}
+		attributes = append(attributes, attribute)
+	}
+	if len(attributes) > 0 {
+		headers = append(headers, attributes)
+	}
+
+	return headers, nil
+}
+
+// unquoteLWP returns the value of the quoted string at the start of s, in
+// which backslashes escape the following character, and the rest of s.
+func unquoteLWP(s string) (string, string, error) {
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", fmt.Errorf("unterminated quoted value")
+			}
+			b.WriteByte(s[i])
+		case '"':
+			return b.String(), s[i+1:], nil
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return "", "", fmt.Errorf("unterminated quoted value")
+}
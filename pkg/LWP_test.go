@@ -0,0 +1,68 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLWPCookies(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteLWPCookies(&buf, formatTestCookies()); err != nil {
+		t.Fatal(err)
+	}
+
+	// As written by Python's LWPCookieJar for the same cookies.
+	want := "#LWP-Cookies-2.0\n" +
+		`Set-Cookie3: session="abc 123;\"q\""; path="/"; domain=".example.com"; path_spec; domain_dot; secure; expires="2038-01-19 03:14:07Z"; HttpOnly=None; version=0` + "\n" +
+		`Set-Cookie3: prefs=dark; path="/settings"; domain="www.example.com"; path_spec; discard; version=0` + "\n"
+	if buf.String() != want {
+		t.Fatalf("Want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	cookies, err := ReadLWPCookies(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCookies := formatTestCookies()
+	if len(cookies) == len(wantCookies) && cookies[0].Expires.Equal(wantCookies[0].Expires) {
+		cookies[0].Expires = wantCookies[0].Expires
+	}
+	if !reflect.DeepEqual(cookies, wantCookies) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v", cookies[0], cookies[1])
+	}
+}
+
+func TestReadLWPCookies(t *testing.T) {
+	file := "#LWP-Cookies-2.0\n" +
+		`Set-Cookie3: sid=42; path="/"; domain="example.com"; port="80,8080"; path_spec; port_spec; discard; comment="a \"comment\""; commenturl="https://example.com/c"; version=0` + "\n" +
+		`Set-Cookie3: a=1; domain=".example.org"; expires="2030-01-01 00:00:00Z", b=2; domain=".example.org"; discard` + "\n"
+
+	cookies, err := ReadLWPCookies(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+
+	c := cookies[0]
+	if c.Name != "sid" || c.Value != "42" || !c.HostOnly || c.Persistent || !reflect.DeepEqual(c.Ports, []int{80, 8080}) {
+		t.Errorf("Want a host-only session cookie with ports; got %+v", c)
+	}
+	if c.Comment != `a "comment"` || c.CommentURL != "https://example.com/c" {
+		t.Errorf("Want comment and comment URL; got %q, %q", c.Comment, c.CommentURL)
+	}
+	if c := cookies[1]; c.Name != "a" || c.HostOnly || !c.Persistent || !c.Expires.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Want a persistent domain cookie; got %+v", c)
+	}
+	if c := cookies[2]; c.Name != "b" || c.Value != "2" || c.Persistent {
+		t.Errorf("Want the second cookie of the line; got %+v", c)
+	}
+
+	if _, err := ReadLWPCookies(strings.NewReader(`Set-Cookie3: a="1; path=/` + "\n")); err == nil {
+		t.Error("Want an error for an unterminated quoted value")
+	}
+}
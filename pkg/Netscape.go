@@ -0,0 +1,151 @@
+package kooky
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The Netscape cookies.txt format is read and written by curl, wget, yt-dlp
+// and Python's http.cookiejar.MozillaCookieJar. Each cookie is a line of
+// seven tab-separated fields:
+//
+//	domain  include subdomains  path  secure  expires  name  value
+//
+// Session cookies expire at 0, and curl prefixes the domain of HttpOnly
+// cookies with "#HttpOnly_".
+
+const (
+	netscapeHeader    = "# Netscape HTTP Cookie File"
+	httpOnlyPrefix    = "#HttpOnly_"
+	netscapeFields    = 7
+	netscapeTrue      = "TRUE"
+	netscapeFalse     = "FALSE"
+	netscapeSeparator = "\t"
+)
+
+// WriteNetscapeCookies writes cookies to w in the Netscape cookies.txt format.
+// Cookies without an expiry are written as session cookies.
+func WriteNetscapeCookies(w io.Writer, cookies []*Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, netscapeHeader)
+	fmt.Fprintln(bw)
+
+	for _, cookie := range cookies {
+		for _, field := range []string{cookie.Domain, cookie.Path, cookie.Name, cookie.Value} {
+			if strings.ContainsAny(field, "\t\r\n") {
+				return fmt.Errorf("cookie %q of %s: tab or newline in field %q", cookie.Name, cookie.Domain, field)
+			}
+		}
+
+		domain := cookieDomain(cookie)
+		if cookie.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !cookie.Expires.IsZero() {
+			expires = cookie.Expires.Unix()
+		}
+
+		fmt.Fprintln(bw, strings.Join([]string{
+			domain,
+			netscapeBool(!cookie.HostOnly),
+			cookie.Path,
+			netscapeBool(cookie.Secure),
+			strconv.FormatInt(expires, 10),
+			cookie.Name,
+			cookie.Value,
+		}, netscapeSeparator))
+	}
+
+	return bw.Flush()
+}
+
+// ReadNetscapeCookies reads cookies in the Netscape cookies.txt format from r.
+func ReadNetscapeCookies(r io.Reader) ([]*Cookie, error) {
+	var cookies []*Cookie
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = line[len(httpOnlyPrefix):]
+		} else if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, netscapeSeparator)
+		if len(fields) == netscapeFields-1 {
+			// Cookies without a value lose their last field in some writers.
+			fields = append(fields, "")
+		}
+		if len(fields) != netscapeFields {
+			return nil, fmt.Errorf("line %d: expected %d tab-separated fields; got %d", lineNumber, netscapeFields, len(fields))
+		}
+
+		includeSubdomains, err := parseNetscapeBool(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		secure, err := parseNetscapeBool(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNumber, err)
+		}
+		// Some writers leave the expiry of session cookies empty.
+		var expires int64
+		if fields[4] != "" {
+			expires, err = strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid expiry %q", lineNumber, fields[4])
+			}
+		}
+
+		cookie := &Cookie{
+			Domain:     fields[0],
+			HostOnly:   !includeSubdomains,
+			Path:       fields[2],
+			Secure:     secure,
+			HttpOnly:   httpOnly,
+			Name:       fields[5],
+			Value:      fields[6],
+			Persistent: expires != 0,
+		}
+		if expires != 0 {
+			cookie.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, scanner.Err()
+}
+
+// cookieDomain returns the domain of cookie as written by cookie files, in
+// which cookies sent to subdomains have a leading dot.
+func cookieDomain(cookie *Cookie) string {
+	if !cookie.HostOnly && !strings.HasPrefix(cookie.Domain, ".") {
+		return "." + cookie.Domain
+	}
+	return cookie.Domain
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return netscapeTrue
+	}
+	return netscapeFalse
+}
+
+func parseNetscapeBool(s string) (bool, error) {
+	switch strings.ToUpper(s) {
+	case netscapeTrue:
+		return true, nil
+	case netscapeFalse:
+		return false, nil
+	}
+	return false, fmt.Errorf("expected %s or %s; got %q", netscapeTrue, netscapeFalse, s)
+}
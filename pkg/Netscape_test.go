@@ -0,0 +1,94 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// formatTestCookies are cookies every cookie file format can represent.
+func formatTestCookies() []*Cookie {
+	return []*Cookie{{
+		Domain:     ".example.com",
+		Name:       "session",
+		Path:       "/",
+		Value:      "abc 123;\"q\"",
+		Secure:     true,
+		HttpOnly:   true,
+		Expires:    time.Unix(2147483647, 0),
+		Persistent: true,
+	}, {
+		Domain:   "www.example.com",
+		Name:     "prefs",
+		Path:     "/settings",
+		Value:    "dark",
+		HostOnly: true,
+	}}
+}
+
+func TestNetscapeCookies(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteNetscapeCookies(&buf, formatTestCookies()); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "# Netscape HTTP Cookie File\n\n" +
+		"#HttpOnly_.example.com\tTRUE\t/\tTRUE\t2147483647\tsession\tabc 123;\"q\"\n" +
+		"www.example.com\tFALSE\t/settings\tFALSE\t0\tprefs\tdark\n"
+	if buf.String() != want {
+		t.Fatalf("Want:\n%s\ngot:\n%s", want, buf.String())
+	}
+
+	cookies, err := ReadNetscapeCookies(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cookies, formatTestCookies()) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v", cookies[0], cookies[1])
+	}
+
+	// A domain cookie without a leading dot gains one.
+	buf.Reset()
+	WriteNetscapeCookies(&buf, []*Cookie{{Domain: "example.org", Name: "id", Path: "/", Value: "1"}})
+	if !strings.Contains(buf.String(), "\n.example.org\tTRUE\t") {
+		t.Errorf("Want a leading dot on a domain cookie; got:\n%s", buf.String())
+	}
+}
+
+func TestReadNetscapeCookies(t *testing.T) {
+	// As written by curl, with a cookie without a value and CRLF line endings.
+	file := "# Netscape HTTP Cookie File\r\n" +
+		"# https://curl.se/docs/http-cookies.html\r\n" +
+		"\r\n" +
+		"#HttpOnly_example.com\tFALSE\t/\tFALSE\t0\tsid\t42\r\n" +
+		".example.net\tTRUE\t/app\tTRUE\t1893456000\tempty\r\n"
+
+	cookies, err := ReadNetscapeCookies(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := cookies[0]; c.Domain != "example.com" || !c.HostOnly || !c.HttpOnly || c.Persistent || !c.Expires.IsZero() || c.Value != "42" {
+		t.Errorf("Want a host-only HttpOnly session cookie; got %+v", c)
+	}
+	if c := cookies[1]; c.Domain != ".example.net" || c.HostOnly || !c.Secure || c.Value != "" || !c.Expires.Equal(time.Unix(1893456000, 0)) {
+		t.Errorf("Want a secure domain cookie without value; got %+v", c)
+	}
+
+	// An empty expiry is a session cookie's.
+	cookies, err = ReadNetscapeCookies(strings.NewReader("example.com\tFALSE\t/\tFALSE\t\tid\t1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Persistent || !cookies[0].Expires.IsZero() || cookies[0].Value != "1" {
+		t.Errorf("Want a session cookie for an empty expiry; got %v", cookies)
+	}
+
+	if _, err := ReadNetscapeCookies(strings.NewReader("example.com\tMAYBE\t/\tFALSE\t0\tid\t1\n")); err == nil {
+		t.Error("Want an error for an invalid boolean field")
+	}
+}
@@ -0,0 +1,147 @@
+package kooky
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Playwright saves and restores the state of a browser context, its cookies
+// and local storage, as a storageState JSON file:
+// https://playwright.dev/docs/api/class-browsercontext#browser-context-storage-state
+
+type playwrightStorageState struct {
+	Cookies []playwrightCookie `json:"cookies"`
+	Origins []json.RawMessage  `json:"origins"`
+}
+
+type playwrightCookie struct {
+	Name     string  `json:"name"`
+	Value    string  `json:"value"`
+	URL      string  `json:"url,omitempty"`
+	Domain   string  `json:"domain,omitempty"`
+	Path     string  `json:"path,omitempty"`
+	Expires  float64 `json:"expires"`
+	HttpOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite"`
+}
+
+// playwrightSession is the expiry of session cookies in Playwright.
+const playwrightSession = -1
+
+// WritePlaywrightStorageState writes cookies to w as a Playwright
+// storageState file without local storage. Cookies without a SameSite
+// attribute are written as Lax, which browsers default them to.
+//
+// Playwright sets a cookie with a domain for its subdomains too, so host-only
+// cookies are written with the url of Cookie.URL instead, which keeps the
+// scheme and port they were set from. Playwright takes their path from the
+// directory of the url, so a host-only cookie whose path does not end in a
+// slash, like /settings, cannot be written without widening it to /, and is
+// an error.
+func WritePlaywrightStorageState(w io.Writer, cookies []*Cookie) error {
+	state := playwrightStorageState{
+		Cookies: make([]playwrightCookie, len(cookies)),
+		Origins: []json.RawMessage{},
+	}
+	for i, cookie := range cookies {
+		c := playwrightCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Domain:   cookieDomain(cookie),
+			Path:     cookie.Path,
+			Expires:  playwrightSession,
+			HttpOnly: cookie.HttpOnly,
+			Secure:   cookie.Secure,
+			SameSite: SameSiteLax.String(),
+		}
+		if c.Path == "" {
+			c.Path = "/"
+		}
+		if cookie.HostOnly {
+			if !strings.HasSuffix(c.Path, "/") {
+				return fmt.Errorf("cookie %q of %s: host-only cookie with path %q cannot be written", cookie.Name, cookie.Domain, c.Path)
+			}
+			c.URL = cookie.URL()
+			c.Domain, c.Path = "", ""
+		}
+		if !cookie.Expires.IsZero() {
+			c.Expires = float64(cookie.Expires.Unix()) + float64(cookie.Expires.Nanosecond())/1e9
+		}
+		if cookie.SameSite != SameSiteUnspecified {
+			c.SameSite = cookie.SameSite.String()
+		}
+		state.Cookies[i] = c
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(state)
+}
+
+// ReadPlaywrightStorageState reads the cookies of a Playwright storageState
+// file from r. Local storage is ignored.
+func ReadPlaywrightStorageState(r io.Reader) ([]*Cookie, error) {
+	var state playwrightStorageState
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]*Cookie, len(state.Cookies))
+	for i, c := range state.Cookies {
+		sameSite, ok := parseSameSite(c.SameSite)
+		if !ok {
+			return nil, fmt.Errorf("cookie %d: invalid sameSite %q", i, c.SameSite)
+		}
+
+		cookie := &Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			HttpOnly: c.HttpOnly,
+			Secure:   c.Secure,
+			SameSite: sameSite,
+			HostOnly: !strings.HasPrefix(c.Domain, "."),
+		}
+		if c.URL != "" {
+			// As Playwright does in addCookies, but for the Secure attribute,
+			// which the url's scheme only implies: it is the scheme the
+			// cookie was set from.
+			u, err := url.Parse(c.URL)
+			if err != nil {
+				return nil, fmt.Errorf("cookie %d: %v", i, err)
+			}
+			cookie.Domain = u.Hostname()
+			cookie.Path = u.Path[:strings.LastIndex(u.Path, "/")+1]
+			cookie.HostOnly = true
+			switch u.Scheme {
+			case "https":
+				cookie.SourceScheme = SourceSchemeSecure
+			case "http":
+				cookie.SourceScheme = SourceSchemeNonSecure
+			}
+			if u.Port() != "" {
+				port, err := strconv.Atoi(u.Port())
+				if err != nil {
+					return nil, fmt.Errorf("cookie %d: invalid port in url %q", i, c.URL)
+				}
+				cookie.SourcePort = port
+			}
+		}
+		if c.Expires > 0 {
+			seconds, frac := math.Modf(c.Expires)
+			cookie.Expires = time.Unix(int64(seconds), int64(math.Round(frac*1e6))*1e3)
+			cookie.Persistent = true
+		}
+		cookies[i] = cookie
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,149 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPlaywrightStorageState(t *testing.T) {
+	cookies := formatTestCookies()
+	cookies[0].SameSite = SameSiteStrict
+	cookies[1].SameSite = SameSiteNone
+	// Playwright takes the path of a host-only cookie from the directory of
+	// its url, which also gives the scheme it was set from.
+	cookies[1].Path = "/settings/"
+	cookies[1].SourceScheme = SourceSchemeNonSecure
+
+	var buf bytes.Buffer
+	if err := WritePlaywrightStorageState(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`"expires": 2147483647,`,
+		`"expires": -1,`,
+		`"sameSite": "Strict"`,
+		`"sameSite": "None"`,
+		`"origins": []`,
+		`"domain": ".example.com"`,
+		`"url": "http://www.example.com/settings/"`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Want %s in:\n%s", want, buf.String())
+		}
+	}
+
+	read, err := ReadPlaywrightStorageState(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, cookies) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v", read[0], read[1])
+	}
+
+	// A host-only cookie whose path is not a directory would widen to its
+	// parent.
+	buf.Reset()
+	if err := WritePlaywrightStorageState(&buf, []*Cookie{{Domain: "example.org", Name: "id", Path: "/app", Secure: true, HostOnly: true}}); err == nil {
+		t.Errorf("Want an error writing a host-only cookie with path /app")
+	}
+	buf.Reset()
+	WritePlaywrightStorageState(&buf, []*Cookie{{Domain: "example.org", Name: "id", Path: "/app/", Secure: true, HostOnly: true}})
+	if read, err := ReadPlaywrightStorageState(&buf); err != nil || len(read) != 1 || read[0].Path != "/app/" || !read[0].Secure || !read[0].HostOnly {
+		t.Errorf("Want a secure host-only cookie with path /app/; got %v, %v", read, err)
+	}
+
+	// The url keeps the scheme and port a cookie was set from.
+	for _, cookie := range []*Cookie{
+		{Domain: "example.org", Name: "id", Path: "/", HostOnly: true, SameSite: SameSiteLax, SourceScheme: SourceSchemeSecure},
+		{Domain: "example.org", Name: "id", Path: "/", HostOnly: true, SameSite: SameSiteLax, SourceScheme: SourceSchemeNonSecure, SourcePort: 8080},
+	} {
+		buf.Reset()
+		if err := WritePlaywrightStorageState(&buf, []*Cookie{cookie}); err != nil {
+			t.Fatal(err)
+		}
+		if want := `"url": "` + cookie.URL() + `"`; !strings.Contains(buf.String(), want) {
+			t.Errorf("Want %s in:\n%s", want, buf.String())
+		}
+		read, err := ReadPlaywrightStorageState(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(read) != 1 || !reflect.DeepEqual(read[0], cookie) {
+			t.Errorf("Round trip changed the cookie:\n%+v\n%+v", read[0], cookie)
+		}
+	}
+
+	// Cookies without SameSite are written as Lax.
+	buf.Reset()
+	WritePlaywrightStorageState(&buf, []*Cookie{{Domain: "example.org", Name: "id", Value: "1"}})
+	if !strings.Contains(buf.String(), `"sameSite": "Lax"`) || !strings.Contains(buf.String(), `"path": "/"`) {
+		t.Errorf("Want Lax and a root path for a bare cookie; got:\n%s", buf.String())
+	}
+}
+
+func TestReadPlaywrightStorageState(t *testing.T) {
+	// As saved by context.storageState(), with local storage.
+	state := `{
+  "cookies": [
+    {
+      "name": "token",
+      "value": "xyz",
+      "domain": "app.example.com",
+      "path": "/",
+      "expires": 1893456000.5,
+      "httpOnly": true,
+      "secure": true,
+      "sameSite": "Lax"
+    }
+  ],
+  "origins": [
+    {
+      "origin": "https://app.example.com",
+      "localStorage": [{"name": "theme", "value": "dark"}]
+    }
+  ]
+}`
+
+	cookies, err := ReadPlaywrightStorageState(strings.NewReader(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, but expected 1", len(cookies))
+	}
+	c := cookies[0]
+	if !c.HostOnly || !c.HttpOnly || !c.Secure || c.SameSite != SameSiteLax || !c.Persistent {
+		t.Errorf("Want a persistent host-only secure Lax cookie; got %+v", c)
+	}
+	if want := time.Unix(1893456000, 500000000); !c.Expires.Equal(want) {
+		t.Errorf("Want expiry %v; got %v", want, c.Expires)
+	}
+
+	// Cookies set from a url, not secure over https, and on another port.
+	state = `{
+  "cookies": [
+    {"name": "plain", "value": "1", "url": "https://example.com/", "expires": -1, "httpOnly": false, "secure": false, "sameSite": "Lax"},
+    {"name": "dev", "value": "2", "url": "http://localhost:8080/app/", "expires": -1, "httpOnly": false, "secure": false, "sameSite": "Lax"}
+  ]
+}`
+	cookies, err = ReadPlaywrightStorageState(strings.NewReader(state))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := cookies[0]; c.Secure || c.SourceScheme != SourceSchemeSecure || c.SourcePort != 0 || !c.HostOnly {
+		t.Errorf("Want a host-only cookie that is not secure, set over https; got %+v", c)
+	}
+	if c := cookies[1]; c.Domain != "localhost" || c.Path != "/app/" || c.SourceScheme != SourceSchemeNonSecure || c.SourcePort != 8080 {
+		t.Errorf("Want a cookie of localhost set on port 8080; got %+v", c)
+	}
+
+	if _, err := ReadPlaywrightStorageState(strings.NewReader(`{"cookies": [{"sameSite": "Sometimes"}]}`)); err == nil {
+		t.Error("Want an error for an invalid sameSite")
+	}
+}
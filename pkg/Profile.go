@@ -0,0 +1,38 @@
+package kooky
+
+import "errors"
+
+// Profile is a browser profile with its own cookie store.
+type Profile struct {
+	// ID identifies the profile within the browser, e.g. the directory
+	// name "Profile 1" for Chrome.
+	ID string
+
+	// Name is the name the browser displays for the profile.
+	Name string
+
+	// Email is the address of the account signed in to the profile, if any.
+	Email string
+
+	Dir        string
+	CookieFile string
+}
+
+// ErrProfileNotFound is returned when no profile has the requested ID or name.
+var ErrProfileNotFound = errors.New("profile not found")
+
+// FindProfile returns the profile whose ID, or else whose name, is name.
+func FindProfile(name string, profiles []Profile) *Profile {
+	for i := range profiles {
+		if profiles[i].ID == name {
+			return &profiles[i]
+		}
+	}
+	for i := range profiles {
+		if profiles[i].Name == name {
+			return &profiles[i]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package kooky
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// WebDriverCookie is a cookie as serialized by WebDriver, which Selenium's
+// add_cookie and get_cookies take and return:
+// https://www.w3.org/TR/webdriver2/#cookies
+type WebDriverCookie struct {
+	Name     string `json:"name"`
+	Value    string `json:"value"`
+	Path     string `json:"path,omitempty"`
+	Domain   string `json:"domain,omitempty"`
+	Secure   bool   `json:"secure"`
+	HTTPOnly bool   `json:"httpOnly"`
+	Expiry   *int64 `json:"expiry,omitempty"`
+	SameSite string `json:"sameSite,omitempty"`
+
+	// URL is the page to navigate to before adding the cookie, as WebDriver
+	// only adds cookies for the domain of the current page. It is not a
+	// WebDriver field, and is removed before the cookie is added. A host-only
+	// cookie has no Domain, and is set for the host of its URL.
+	URL string `json:"url,omitempty"`
+}
+
+// WebDriverCookies converts cookies to WebDriver cookies. Host-only cookies
+// are left without a domain, as WebDriver widens a cookie with one to the
+// subdomains of the domain.
+func WebDriverCookies(cookies []*Cookie) []WebDriverCookie {
+	out := make([]WebDriverCookie, len(cookies))
+	for i, cookie := range cookies {
+		c := WebDriverCookie{
+			Name:     cookie.Name,
+			Value:    cookie.Value,
+			Path:     cookie.Path,
+			Secure:   cookie.Secure,
+			HTTPOnly: cookie.HttpOnly,
+			URL:      cookie.URL(),
+		}
+		if !cookie.HostOnly {
+			c.Domain = cookieDomain(cookie)
+		}
+		if !cookie.Expires.IsZero() {
+			expiry := cookie.Expires.Unix()
+			c.Expiry = &expiry
+		}
+		if cookie.SameSite != SameSiteUnspecified {
+			c.SameSite = cookie.SameSite.String()
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// WriteWebDriverJSON writes cookies to w as a JSON array of WebDriver
+// cookies, as saved from Selenium's get_cookies, each with the url of the
+// page to add it from.
+func WriteWebDriverJSON(w io.Writer, cookies []*Cookie) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(WebDriverCookies(cookies))
+}
+
+// ReadWebDriverJSON reads a JSON array of WebDriver cookies from r.
+func ReadWebDriverJSON(r io.Reader) ([]*Cookie, error) {
+	var in []WebDriverCookie
+	if err := json.NewDecoder(r).Decode(&in); err != nil {
+		return nil, err
+	}
+
+	cookies := make([]*Cookie, len(in))
+	for i, c := range in {
+		sameSite, ok := parseSameSite(c.SameSite)
+		if !ok {
+			return nil, fmt.Errorf("cookie %d: invalid sameSite %q", i, c.SameSite)
+		}
+
+		cookie := &Cookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Path:     c.Path,
+			Domain:   c.Domain,
+			Secure:   c.Secure,
+			HttpOnly: c.HTTPOnly,
+			SameSite: sameSite,
+			HostOnly: !strings.HasPrefix(c.Domain, "."),
+		}
+		if c.Domain == "" && c.URL != "" {
+			u, err := url.Parse(c.URL)
+			if err != nil {
+				return nil, fmt.Errorf("cookie %d: %v", i, err)
+			}
+			cookie.Domain = u.Hostname()
+		}
+		if c.Expiry != nil {
+			cookie.Expires = time.Unix(*c.Expiry, 0)
+			cookie.Persistent = true
+		}
+		cookies[i] = cookie
+	}
+
+	return cookies, nil
+}
@@ -0,0 +1,75 @@
+package kooky
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWebDriverCookies(t *testing.T) {
+	cookies := formatTestCookies()
+	cookies[1].SourceScheme = SourceSchemeSecure
+	cookies[1].SourcePort = 8443
+
+	webDriverCookies := WebDriverCookies(cookies)
+	if got, want := webDriverCookies[0].URL, "https://example.com/"; got != want {
+		t.Errorf("Want URL %s; got %s", want, got)
+	}
+	if got, want := webDriverCookies[1].URL, "https://www.example.com:8443/settings"; got != want {
+		t.Errorf("Want URL %s; got %s", want, got)
+	}
+	if c := webDriverCookies[0]; c.Expiry == nil || *c.Expiry != 2147483647 {
+		t.Errorf("Want expiry 2147483647; got %v", c.Expiry)
+	}
+	if c := webDriverCookies[1]; c.Expiry != nil {
+		t.Errorf("Want no expiry for a session cookie; got %d", *c.Expiry)
+	}
+	if got := webDriverCookies[0].Domain; got != ".example.com" {
+		t.Errorf("Want domain .example.com; got %q", got)
+	}
+	if got := webDriverCookies[1].Domain; got != "" {
+		t.Errorf("Want no domain for a host-only cookie; got %q", got)
+	}
+}
+
+func TestWebDriverJSON(t *testing.T) {
+	cookies := formatTestCookies()
+	cookies[0].SameSite = SameSiteStrict
+
+	var buf bytes.Buffer
+	if err := WriteWebDriverJSON(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"url": "http://www.example.com/settings"`) || strings.Count(buf.String(), `"expiry"`) != 1 {
+		t.Errorf("Want one expiry and the URL of the host-only cookie in:\n%s", buf.String())
+	}
+
+	read, err := ReadWebDriverJSON(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, cookies) {
+		t.Errorf("Round trip changed the cookies:\n%+v\n%+v", read[0], read[1])
+	}
+}
+
+func TestReadWebDriverJSON(t *testing.T) {
+	// As saved from Selenium's get_cookies.
+	export := `[{"domain": ".example.com", "expiry": 1893456000, "httpOnly": false, "name": "_ga", "path": "/", "sameSite": "Lax", "secure": false, "value": "GA1.2"},
+{"domain": "www.example.com", "httpOnly": true, "name": "sid", "path": "/", "secure": true, "value": "s"}]`
+
+	cookies, err := ReadWebDriverJSON(strings.NewReader(export))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := cookies[0]; c.HostOnly || c.SameSite != SameSiteLax || !c.Persistent || c.Expires.Unix() != 1893456000 {
+		t.Errorf("Want a persistent Lax domain cookie; got %+v", c)
+	}
+	if c := cookies[1]; !c.HostOnly || !c.HttpOnly || !c.Secure || c.Persistent || !c.Expires.IsZero() {
+		t.Errorf("Want a host-only secure session cookie; got %+v", c)
+	}
+}
@@ -0,0 +1,9 @@
+package brave
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Brave browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("brave")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}
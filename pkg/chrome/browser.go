@@ -0,0 +1,237 @@
+package chrome
+
+import (
+	"runtime"
+
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// Browser describes a browser storing its cookies the way Chrome does,
+// such as Chromium, Brave or Edge.
+type Browser struct {
+	// Name is recorded as the Browser of the cookies read, e.g. "brave".
+	Name string
+
+	// UserDataPaths holds the user data directory containing the profiles
+	// per operating system, relative to the home directory of the current user.
+	UserDataPaths kooky.DefaultPathMap
+
+	// DefaultProfile is the directory of the default profile within the
+	// user data directory. It is empty for browsers like Opera, whose user
+	// data directory is the profile.
+	DefaultProfile string
+
+	// InstallPaths holds the absolute path of the browser executable per
+	// operating system.
+	InstallPaths kooky.DefaultPathMap
+
+	// SafeStorage names the entry the browser keeps its Safe Storage
+	// password in on macOS and Linux.
+	SafeStorage SafeStorage
+}
+
+// LookupBrowser returns the Browser named name: "chrome", or one of the
+// other browsers built on Chromium whose packages return readers for them,
+// "chromium", "brave", "edge", "opera", "vivaldi" and "yandex". Each call
+// returns a new Browser, which the caller may change.
+func LookupBrowser(name string) (Browser, bool) {
+	newBrowser, ok := browsers[name]
+	if !ok {
+		return Browser{}, false
+	}
+	return newBrowser(), true
+}
+
+// browsers holds the known browsers by name.
+var browsers = map[string]func() Browser{
+	"chrome":   chromeBrowser,
+	"chromium": chromiumBrowser,
+	"brave":    braveBrowser,
+	"edge":     edgeBrowser,
+	"opera":    operaBrowser,
+	"vivaldi":  vivaldiBrowser,
+	"yandex":   yandexBrowser,
+}
+
+// chromeBrowser returns Google Chrome.
+func chromeBrowser() Browser {
+	return Browser{
+		Name: "chrome",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\Google\Chrome\User Data`,
+			"darwin":  "Library/Application Support/Google/Chrome",
+			"linux":   ".config/google-chrome",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`,
+			"darwin":  "/Applications/Google Chrome.app/Contents/MacOs/Google Chrome",
+			"linux":   "/usr/bin/google-chrome",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Chrome Safe Storage",
+			Account:      "Chrome",
+			Applications: []string{"chrome", "chromium"},
+		},
+	}
+}
+
+func chromiumBrowser() Browser {
+	return Browser{
+		Name: "chromium",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\Chromium\User Data`,
+			"darwin":  "Library/Application Support/Chromium",
+			"linux":   ".config/chromium",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files\Chromium\Application\chrome.exe`,
+			"darwin":  "/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"linux":   "/usr/bin/chromium",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Chromium Safe Storage",
+			Account:      "Chromium",
+			Applications: []string{"chromium"},
+		},
+	}
+}
+
+func braveBrowser() Browser {
+	return Browser{
+		Name: "brave",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\BraveSoftware\Brave-Browser\User Data`,
+			"darwin":  "Library/Application Support/BraveSoftware/Brave-Browser",
+			"linux":   ".config/BraveSoftware/Brave-Browser",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files\BraveSoftware\Brave-Browser\Application\brave.exe`,
+			"darwin":  "/Applications/Brave Browser.app/Contents/MacOS/Brave Browser",
+			"linux":   "/usr/bin/brave-browser",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Brave Safe Storage",
+			Account:      "Brave",
+			Applications: []string{"brave"},
+		},
+	}
+}
+
+func edgeBrowser() Browser {
+	return Browser{
+		Name: "edge",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\Microsoft\Edge\User Data`,
+			"darwin":  "Library/Application Support/Microsoft Edge",
+			"linux":   ".config/microsoft-edge",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files (x86)\Microsoft\Edge\Application\msedge.exe`,
+			"darwin":  "/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+			"linux":   "/usr/bin/microsoft-edge",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Microsoft Edge Safe Storage",
+			Account:      "Microsoft Edge",
+			Applications: []string{"microsoft-edge"},
+		},
+	}
+}
+
+// operaBrowser returns Opera, whose user data directory is its only
+// profile.
+func operaBrowser() Browser {
+	return Browser{
+		Name: "opera",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Roaming\Opera Software\Opera Stable`,
+			"darwin":  "Library/Application Support/com.operasoftware.Opera",
+			"linux":   ".config/opera",
+		}),
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files\Opera\launcher.exe`,
+			"darwin":  "/Applications/Opera.app/Contents/MacOS/Opera",
+			"linux":   "/usr/bin/opera",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Opera Safe Storage",
+			Account:      "Opera",
+			Applications: []string{"opera"},
+		},
+	}
+}
+
+func vivaldiBrowser() Browser {
+	return Browser{
+		Name: "vivaldi",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\Vivaldi\User Data`,
+			"darwin":  "Library/Application Support/Vivaldi",
+			"linux":   ".config/vivaldi",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files\Vivaldi\Application\vivaldi.exe`,
+			"darwin":  "/Applications/Vivaldi.app/Contents/MacOS/Vivaldi",
+			"linux":   "/usr/bin/vivaldi",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Vivaldi Safe Storage",
+			Account:      "Vivaldi",
+			Applications: []string{"vivaldi"},
+		},
+	}
+}
+
+func yandexBrowser() Browser {
+	return Browser{
+		Name: "yandex",
+		UserDataPaths: pathMap(map[string]string{
+			"windows": `AppData\Local\Yandex\YandexBrowser\User Data`,
+			"darwin":  "Library/Application Support/Yandex/YandexBrowser",
+			"linux":   ".config/yandex-browser",
+		}),
+		DefaultProfile: "Default",
+		InstallPaths: pathMap(map[string]string{
+			"windows": `C:\Program Files (x86)\Yandex\YandexBrowser\Application\browser.exe`,
+			"darwin":  "/Applications/Yandex.app/Contents/MacOS/Yandex",
+			"linux":   "/usr/bin/yandex-browser",
+		}),
+		SafeStorage: SafeStorage{
+			Service:      "Yandex Safe Storage",
+			Account:      "Yandex",
+			Applications: []string{"yandex-browser"},
+		},
+	}
+}
+
+// pathMap returns a DefaultPathMap of the paths per operating system.
+func pathMap(paths map[string]string) kooky.DefaultPathMap {
+	pathMap := kooky.NewDefaultPathMap()
+	for operatingSystem, path := range paths {
+		pathMap.Add(operatingSystem, path)
+	}
+	return pathMap
+}
+
+// NewBrowserCookieReader returns a new CookieReader for a browser built on
+// Chromium, reusing Chrome's cookie database parsing and decryption.
+func NewBrowserCookieReader(browser Browser, options ...Option) CookieReader {
+	reader := CookieReader{
+		browser:                browser.Name,
+		userDataPathMap:        browser.UserDataPaths,
+		defaultProfile:         browser.DefaultProfile,
+		installLocationPathMap: browser.InstallPaths,
+		operatingSystem:        runtime.GOOS,
+		keyring:                CachedKeyProvider(KeyringPassword(browser.SafeStorage)),
+	}
+	for _, option := range options {
+		option(&reader)
+	}
+
+	return reader
+}
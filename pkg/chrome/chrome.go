@@ -1,43 +1,89 @@
 package chrome
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
-	"os/user"
+	"io"
+	"io/fs"
+	"io/ioutil"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-sqlite/sqlite3"
+	"github.com/kgoins/kooky/internal/sqliteutil"
+	"github.com/kgoins/kooky/internal/storefs"
 	kooky "github.com/kgoins/kooky/pkg"
 )
 
-var cookiePathMap kooky.DefaultPathMap
-var installLocationPathMap kooky.DefaultPathMap
+// CookieReader implements kooky.KookyReader for the Chrome browser
+type CookieReader struct {
+	browser                string
+	userDataPathMap        kooky.DefaultPathMap
+	defaultProfile         string
+	installLocationPathMap kooky.DefaultPathMap
 
-func init() {
-	cookiePathMap = kooky.NewDefaultPathMap()
-	cookiePathMap.Add("windows", `AppData\Local\Google\Chrome\User Data\Default`)
-	cookiePathMap.Add("darwin", "Library/Application Support/Google/Chrome/Default")
-	cookiePathMap.Add("linux", ".config/google-chrome/Default")
+	// operatingSystem selects the encryption scheme of cookie values.
+	operatingSystem string
+	keys            KeyProvider
+	keyring         KeyProvider
+	noV10Fallback   bool
+}
 
-	installLocationPathMap = kooky.NewDefaultPathMap()
-	installLocationPathMap.Add("windows", `C:\Program Files (x86)\Google\Chrome\Application\chrome.exe`)
-	installLocationPathMap.Add("darwin", "/Applications/Google Chrome.app/Contents/MacOs/Google Chrome")
-	installLocationPathMap.Add("linux", "/usr/bin/google-chrome")
+// Option configures a CookieReader.
+type Option func(*CookieReader)
+
+// WithKeyProvider decrypts cookie values with the key supplied by keys
+// instead of the one stored by the browser on this machine.
+func WithKeyProvider(keys KeyProvider) Option {
+	return func(reader *CookieReader) {
+		reader.keys = keys
+	}
 }
 
-// CookieReader implements kooky.KookyReader for the Chrome browser
-type CookieReader struct {
-	cookiePathMap          kooky.DefaultPathMap
-	installLocationPathMap kooky.DefaultPathMap
+// WithoutV10Fallback fails to decrypt "v11" cookie values on Linux if the
+// key provider fails, e.g. without a keyring. By default they are decrypted
+// with the hardcoded "v10" password instead, as kooky did before key providers.
+func WithoutV10Fallback() Option {
+	return func(reader *CookieReader) {
+		reader.noV10Fallback = true
+	}
+}
+
+// WithOperatingSystem decrypts cookie values the way Chrome on
+// operatingSystem encrypts them, e.g. to read a cookie file copied from
+// another machine. It defaults to the current operating system.
+func WithOperatingSystem(operatingSystem string) Option {
+	return func(reader *CookieReader) {
+		reader.operatingSystem = operatingSystem
+	}
 }
 
 // NewCookieReader returns a new CookieReader
-func NewCookieReader() CookieReader {
-	return CookieReader{
-		cookiePathMap:          cookiePathMap,
-		installLocationPathMap: installLocationPathMap,
+func NewCookieReader(options ...Option) CookieReader {
+	return NewBrowserCookieReader(chromeBrowser(), options...)
+}
+
+// keyProvider returns the KeyProvider for the cookie file filename in
+// files: the one given with WithKeyProvider, or else the browser's own key,
+// which Chrome on Windows stores in the Local State file next to the profiles.
+func (reader CookieReader) keyProvider(files storefs.FS, filename string) KeyProvider {
+	if reader.keys != nil {
+		return reader.keys
 	}
+
+	if reader.operatingSystem == "windows" {
+		localStatePath, err := findLocalState(files, filename)
+		if err != nil {
+			return KeyProviderFunc(func() (Key, error) {
+				return Key{}, err
+			})
+		}
+		return localStateKey(files, localStatePath, defaultKeyUnwrapper())
+	}
+
+	return reader.keyring
 }
 
 // GetDefaultInstallPath returns the absolute filepath for the default install location on the current OS.
@@ -52,151 +98,313 @@ func (reader CookieReader) GetDefaultInstallPath(operatingSystem string) (string
 
 // GetDefaultCookieFilePath returns the absolute filepath for the file used to store cookies on the current OS.
 func (reader CookieReader) GetDefaultCookieFilePath(operatingSystem string) (string, error) {
-	path, found := reader.cookiePathMap.Get(operatingSystem)
-	if !found {
-		return "", errors.New("Unsupported operating system")
-	}
-
-	currentUser, err := user.Current()
+	userDataDir, err := reader.userDataDir(operatingSystem)
 	if err != nil {
 		return "", err
 	}
 
-	profileDirPath := filepath.Join(currentUser.HomeDir, path)
-	return filepath.Join(profileDirPath, "Cookies"), nil
+	return cookieFile(filepath.Join(userDataDir, reader.defaultProfile)), nil
 }
 
 // ReadAllCookies reads all cookies from the input sqlite database filepath.
 func (reader CookieReader) ReadAllCookies(filename string) ([]*kooky.Cookie, error) {
-	return reader.ReadCookies(filename, "", "", time.Time{})
+	return reader.ReadCookies(filename)
 }
 
-// ReadCookies reads cookies from the input chrome sqlite database filepath, filtered by the input parameters.
-func (reader CookieReader) ReadCookies(filename string, domainFilter string, nameFilter string, expireAfter time.Time) ([]*kooky.Cookie, error) {
-	var cookies []*kooky.Cookie
-	db, err := sqlite3.Open(filename)
+// ReadCookies reads cookies from the input chrome sqlite database filepath, filtered by the input filters.
+// Filters are applied before values are decrypted.
+func (reader CookieReader) ReadCookies(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.OS(), filename, true, filters)
+	return cookies, err
+}
+
+// ReadCookiesLenient reads cookies like ReadCookies, but skips the cookies it fails to read or
+// decrypt, returning an error for each of them.
+func (reader CookieReader) ReadCookiesLenient(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	return reader.readCookies(storefs.OS(), filename, false, filters)
+}
+
+// ReadCookiesFS reads cookies like ReadCookies from the file name in fsys, e.g. "Default/Cookies" in a
+// zip archive of the user data directory. Its write-ahead log and Local State are looked up in fsys.
+func (reader CookieReader) ReadCookiesFS(fsys fs.FS, name string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.FromFS(fsys), name, true, filters)
+	return cookies, err
+}
+
+// ReadCookiesFrom reads cookies like ReadCookies from the size bytes of a cookie database in r.
+// Without a Local State to read it from, Windows cookies need a key given with WithKeyProvider.
+func (reader CookieReader) ReadCookiesFrom(r io.ReaderAt, size int64, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, size))
 	if err != nil {
 		return nil, err
 	}
-	defer db.Close()
 
-	err = db.VisitTableRecords("cookies", func(rowId *int64, rec sqlite3.Record) error {
-		if rowId == nil {
-			return fmt.Errorf("unexpected nil RowID in Chrome sqlite database")
-		}
-		cookie := &kooky.Cookie{}
+	cookies, _, err := reader.readCookies(storefs.Memory(data), "", true, filters)
+	return cookies, err
+}
 
-		// TODO(zellyn): handle older, shorter rows?
-		if len(rec.Values) < 14 {
-			return fmt.Errorf("expected at least 14 columns in cookie file, got: %d", len(rec.Values))
-		}
+// readCookies reads the cookies of filename in files. In strict mode the first cookie that fails aborts the read.
+func (reader CookieReader) readCookies(files storefs.FS, filename string, strict bool, filters []kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	var cookies []*kooky.Cookie
+	var cookieErrors []kooky.CookieError
 
-		/*
-			-- taken from chrome 80's cookies' sqlite_master
-			CREATE TABLE cookies(
-				creation_utc INTEGER NOT NULL,
-				host_key TEXT NOT NULL,
-				name TEXT NOT NULL,
-				value TEXT NOT NULL,
-				path TEXT NOT NULL,
-				expires_utc INTEGER NOT NULL,
-				is_secure INTEGER NOT NULL,
-				is_httponly INTEGER NOT NULL,
-				last_access_utc INTEGER NOT NULL,
-				has_expires INTEGER NOT NULL DEFAULT 1,
-				is_persistent INTEGER NOT NULL DEFAULT 1,
-				priority INTEGER NOT NULL DEFAULT 1,
-				encrypted_value BLOB DEFAULT '',
-				samesite INTEGER NOT NULL DEFAULT -1,
-				source_scheme INTEGER NOT NULL DEFAULT 0,
-				UNIQUE (host_key, name, path)
-			)
-		*/
-
-		domain, ok := rec.Values[1].(string)
-		if !ok {
-			return fmt.Errorf("expected column 2 (host_key) to to be string; got %T", rec.Values[1])
-		}
-		name, ok := rec.Values[2].(string)
-		if !ok {
-			return fmt.Errorf("expected column 3 (name) in cookie(domain:%s) to to be string; got %T", domain, rec.Values[2])
-		}
-		value, ok := rec.Values[3].(string)
-		if !ok {
-			return fmt.Errorf("expected column 4 (value) in cookie(domain:%s, name:%s) to to be string; got %T", domain, name, rec.Values[3])
-		}
-		path, ok := rec.Values[4].(string)
-		if !ok {
-			return fmt.Errorf("expected column 5 (path) in cookie(domain:%s, name:%s) to to be string; got %T", domain, name, rec.Values[4])
-		}
+	// Read a snapshot including the write-ahead log, which holds the
+	// cookies set since the browser last checkpointed the database.
+	f, err := sqliteutil.ReadSnapshot(files, filename)
+	if err != nil {
+		return nil, nil, err
+	}
 
-		var expiresUTC int64
-		switch i := rec.Values[5].(type) {
-		case int64:
-			expiresUTC = i
-		case int:
-			if i != 0 {
-				return fmt.Errorf("expected column 6 (expires_utc) in cookie(domain:%s, name:%s) to to be int64 or int with value=0; got %T with value %v", domain, name, rec.Values[5], rec.Values[5])
-			}
-		default:
-			return fmt.Errorf("expected column 6 (expires_utc) in cookie(domain:%s, name:%s) to to be int64 or int with value=0; got %T with value %v", domain, name, rec.Values[5], rec.Values[5])
+	// Chrome has added, renamed and reordered columns over time, so
+	// columns are looked up by name rather than by position.
+	columns, err := sqliteutil.ReadColumns(f, "cookies")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, column := range [][]string{colHostKey, colName, colPath} {
+		if !columns.Has(column...) {
+			return nil, nil, fmt.Errorf("expected column %q in cookies table", column[0])
 		}
+	}
 
-		encryptedValue, ok := rec.Values[12].([]byte)
-		if !ok {
-			return fmt.Errorf("expected column 13 (encrypted_value) in cookie(domain:%s, name:%s) to to be []byte; got %T", domain, name, rec.Values[12])
-		}
+	db, err := sqlite3.OpenFrom(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
 
-		var expiry time.Time
-		if expiresUTC != 0 {
-			expiry = chromeCookieDate(expiresUTC)
-		}
-		creation := chromeCookieDate(*rowId)
+	decrypter := &decrypter{
+		operatingSystem: reader.operatingSystem,
+		keys:            reader.keyProvider(files, filename),
+		v10Fallback:     !reader.noV10Fallback,
+	}
+	version, err := sqliteutil.MetaVersion(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	hashedDomain := version >= domainHashVersion
 
-		if domainFilter != "" && domain != domainFilter {
+	err = sqliteutil.VisitTableRecords(db, "cookies", func(rowId *int64, rec sqlite3.Record) error {
+		var id int64 = -1
+		if rowId != nil {
+			id = *rowId
+		}
+		var domain, name string
+		fail := func(stage kooky.ErrorStage, err error) error {
+			cookieErr := kooky.CookieError{RowID: id, Domain: domain, Name: name, Stage: stage, Err: err}
+			if strict {
+				return cookieErr
+			}
+			cookieErrors = append(cookieErrors, cookieErr)
 			return nil
 		}
 
-		if nameFilter != "" && name != nameFilter {
-			return nil
+		if rowId == nil {
+			return fail(kooky.StageParse, errors.New("unexpected nil RowID in Chrome sqlite database"))
 		}
 
-		if !expiry.IsZero() && expiry.Before(expireAfter) {
-			return nil
+		cookie, encryptedValue, err := recordCookie(columns, rec.Values, *rowId)
+		domain, name = cookie.Domain, cookie.Name
+		if err != nil {
+			return fail(kooky.StageConvert, err)
 		}
 
-		cookie.Domain = domain
-		cookie.Name = name
-		cookie.Path = path
-		cookie.Expires = expiry
-		cookie.Creation = creation
-		cookie.Secure = rec.Values[6] == 1
-		cookie.HttpOnly = rec.Values[7] == 1
+		cookie.Browser = reader.browser
+		cookie.Profile = profileName(files, filename)
+		cookie.File = filename
+
+		if !kooky.FilterCookie(cookie, filters...) {
+			return nil
+		}
 
 		if len(encryptedValue) > 0 {
-			decrypted, err := decryptValue(encryptedValue)
+			decrypted, err := decrypter.decrypt(encryptedValue)
 			if err != nil {
-				return fmt.Errorf("decrypting cookie %v: %v", cookie, err)
+				return fail(kooky.StageDecrypt, err)
+			}
+			if hashedDomain {
+				decrypted, err = stripDomainHash(decrypted, domain)
+				if err != nil {
+					return fail(kooky.StageDecrypt, err)
+				}
 			}
 			cookie.Value = decrypted
-		} else {
-			cookie.Value = value
 		}
 		cookies = append(cookies, cookie)
 
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		if strict {
+			return nil, nil, err
+		}
+		cookieErrors = append(cookieErrors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: err})
+	}
+
+	return cookies, cookieErrors, nil
+}
+
+// Names of the columns of the cookies table. Columns renamed across Chrome
+// versions list the current name first.
+var (
+	colCreationUTC    = []string{"creation_utc"}
+	colHostKey        = []string{"host_key"}
+	colName           = []string{"name"}
+	colValue          = []string{"value"}
+	colEncryptedValue = []string{"encrypted_value"}
+	colPath           = []string{"path"}
+	colExpiresUTC     = []string{"expires_utc"}
+	colHasExpires     = []string{"has_expires"}
+	colIsSecure       = []string{"is_secure", "secure"}
+	colIsHTTPOnly     = []string{"is_httponly", "httponly"}
+	colLastAccessUTC  = []string{"last_access_utc"}
+	colIsPersistent   = []string{"is_persistent", "persistent"}
+	colPriority       = []string{"priority"}
+	colSameSite       = []string{"samesite", "firstpartyonly"}
+	colSourceScheme   = []string{"source_scheme"}
+	colSourcePort     = []string{"source_port"}
+)
+
+// recordCookie converts the values of the row rowID of the cookies table,
+// except for the encrypted value, which it returns as is. On error, the
+// cookie holds what could be converted.
+func recordCookie(columns sqliteutil.Columns, values []interface{}, rowID int64) (*kooky.Cookie, []byte, error) {
+	cookie := &kooky.Cookie{}
+
+	var ok bool
+	cookie.Domain, ok = columns.Value(values, colHostKey...).(string)
+	if !ok {
+		return cookie, nil, fmt.Errorf("expected column host_key to be string; got %T", columns.Value(values, colHostKey...))
+	}
+	cookie.Name, ok = columns.Value(values, colName...).(string)
+	if !ok {
+		return cookie, nil, fmt.Errorf("expected column name to be string; got %T", columns.Value(values, colName...))
+	}
+	cookie.Path, ok = columns.Value(values, colPath...).(string)
+	if !ok {
+		return cookie, nil, fmt.Errorf("expected column path to be string; got %T", columns.Value(values, colPath...))
+	}
+
+	cookie.Value, ok = columns.Value(values, colValue...).(string)
+	if !ok && columns.Value(values, colValue...) != nil {
+		return cookie, nil, fmt.Errorf("expected column value to be string; got %T", columns.Value(values, colValue...))
+	}
+	encryptedValue, ok := columns.Value(values, colEncryptedValue...).([]byte)
+	if !ok && columns.Value(values, colEncryptedValue...) != nil {
+		return cookie, nil, fmt.Errorf("expected column encrypted_value to be []byte; got %T", columns.Value(values, colEncryptedValue...))
 	}
 
-	return cookies, nil
+	expiresUTC, ok := sqliteutil.ToInt64(columns.Value(values, colExpiresUTC...))
+	if !ok && columns.Value(values, colExpiresUTC...) != nil {
+		return cookie, nil, fmt.Errorf("expected column expires_utc to be an integer; got %T", columns.Value(values, colExpiresUTC...))
+	}
+	// Session cookies may still hold a date in expires_utc.
+	if expiresUTC != 0 && sqliteutil.ColumnInt(columns, values, 1, colHasExpires...) == 1 &&
+		sqliteutil.ColumnInt(columns, values, 1, colIsPersistent...) == 1 {
+		cookie.Expires = chromeCookieDate(expiresUTC)
+	}
 
+	// In older schemas creation_utc is the INTEGER PRIMARY KEY, which
+	// sqlite stores as the rowid rather than in the record.
+	creationUTC, ok := sqliteutil.ToInt64(columns.Value(values, colCreationUTC...))
+	if !ok {
+		creationUTC = rowID
+	}
+	cookie.Creation = chromeCookieDate(creationUTC)
+
+	cookie.Secure = sqliteutil.ColumnInt(columns, values, 0, colIsSecure...) == 1
+	cookie.HttpOnly = sqliteutil.ColumnInt(columns, values, 0, colIsHTTPOnly...) == 1
+	cookie.HostOnly = !strings.HasPrefix(cookie.Domain, ".")
+
+	if lastAccessUTC := sqliteutil.ColumnInt(columns, values, 0, colLastAccessUTC...); lastAccessUTC != 0 {
+		cookie.LastAccess = chromeCookieDate(lastAccessUTC)
+	}
+	cookie.Persistent = sqliteutil.ColumnInt(columns, values, 1, colIsPersistent...) == 1
+	cookie.Priority = chromePriority(sqliteutil.ColumnInt(columns, values, 1, colPriority...))
+	cookie.SameSite = chromeSameSite(sqliteutil.ColumnInt(columns, values, -1, colSameSite...))
+	cookie.SourceScheme = chromeSourceScheme(sqliteutil.ColumnInt(columns, values, 0, colSourceScheme...))
+	// Chrome stores an unspecified or invalid port as -1 or -2.
+	if port := sqliteutil.ColumnInt(columns, values, -1, colSourcePort...); port > 0 {
+		cookie.SourcePort = int(port)
+	}
+
+	return cookie, encryptedValue, nil
+}
+
+// Since version 24 of the cookies database, Chrome prefixes values with the
+// SHA-256 hash of their domain before encrypting them, so that they cannot
+// be moved to another domain.
+const domainHashVersion = 24
+
+// stripDomainHash removes the hash of domain from the start of a decrypted
+// value. A value not starting with it was decrypted with the wrong key or
+// belongs to another domain.
+func stripDomainHash(value string, domain string) (string, error) {
+	hash := sha256.Sum256([]byte(domain))
+	if !strings.HasPrefix(value, string(hash[:])) {
+		return "", errors.New("value does not start with the hash of its domain")
+	}
+	return value[len(hash):], nil
+}
+
+// chromeSameSite converts Chrome's CookieSameSite enum.
+// https://source.chromium.org/chromium/chromium/src/+/main:net/cookies/cookie_constants.h
+func chromeSameSite(samesite int64) kooky.SameSite {
+	switch samesite {
+	case 0:
+		return kooky.SameSiteNone
+	case 1:
+		return kooky.SameSiteLax
+	case 2:
+		return kooky.SameSiteStrict
+	default:
+		return kooky.SameSiteUnspecified
+	}
+}
+
+// chromePriority converts Chrome's CookiePriority enum.
+func chromePriority(priority int64) kooky.Priority {
+	switch priority {
+	case 0:
+		return kooky.PriorityLow
+	case 1:
+		return kooky.PriorityMedium
+	case 2:
+		return kooky.PriorityHigh
+	default:
+		return kooky.PriorityUnspecified
+	}
+}
+
+// chromeSourceScheme converts Chrome's CookieSourceScheme enum.
+func chromeSourceScheme(scheme int64) kooky.SourceScheme {
+	switch scheme {
+	case 1:
+		return kooky.SourceSchemeNonSecure
+	case 2:
+		return kooky.SourceSchemeSecure
+	default:
+		return kooky.SourceSchemeUnset
+	}
 }
 
 // See https://cs.chromium.org/chromium/src/base/time/time.h?l=452&rcl=fceb9a030c182e939a436a540e6dacc70f161cb1
 const windowsToUnixMicrosecondsOffset = 11644473600000000
 
+// profileName returns the name of the profile directory holding the cookie
+// file filename, which is either "<Profile>/Cookies" or "<Profile>/Network/Cookies".
+// A cookie file read from memory has no profile.
+func profileName(files storefs.FS, filename string) string {
+	if filename == "" {
+		return ""
+	}
+	dir := files.Dir(filename)
+	if files.Base(dir) == "Network" {
+		dir = files.Dir(dir)
+	}
+	return files.Base(dir)
+}
+
 // chromeCookieDate converts microseconds to a time.Time object,
 // accounting for the switch to Windows epoch (Jan 1 1601).
 func chromeCookieDate(timestampUTC int64) time.Time {
@@ -7,10 +7,10 @@ import (
 	"runtime"
 )
 
-func setChromeKeychainPassword(password []byte) []byte {
-	return password
-}
-
-func decryptValue(encrypted []byte) (string, error) {
-	return "", fmt.Errorf("decryptValue not implemented on %q", runtime.GOOS)
+// KeyringPassword returns a KeyProvider that always fails: there is no
+// supported keyring on this platform.
+func KeyringPassword(storage SafeStorage) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		return Key{}, fmt.Errorf("KeyringPassword not implemented on %q", runtime.GOOS)
+	})
 }
@@ -1,27 +1,35 @@
 package chrome
 
 import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 	"time"
 
+	"github.com/kgoins/kooky/internal/sqliteutil"
 	"github.com/kgoins/kooky/internal/testutils"
 	kooky "github.com/kgoins/kooky/pkg"
 )
 
 // d18f6247db68045dfbab126d814baf2cf1512141391
 func TestReadChromeCookies(t *testing.T) {
-	// Prevent reading the password from the Keychain on MacOS.
-	oldPassword := setChromeKeychainPassword([]byte("ChromeSafeStoragePasswrd"))
-	defer setChromeKeychainPassword(oldPassword)
-
 	testCookiesPath, err := testutils.GetTestDataFilePath("small-chome-cookie-db.sqlite")
 	if err != nil {
 		t.Fatalf("Failed to load test data file")
 	}
 
-	reader := NewCookieReader()
+	// The test database was copied from Chrome on MacOS; supplying the
+	// password prevents reading it from the Keychain.
+	reader := NewCookieReader(
+		WithKeyProvider(StaticPassword([]byte("ChromeSafeStoragePasswrd"))),
+		WithOperatingSystem("darwin"),
+	)
 
 	cookies, err := reader.ReadAllCookies(testCookiesPath)
 	if err != nil {
@@ -79,3 +87,885 @@ func TestChromeGetDefaultCookiePath(t *testing.T) {
 		t.Fatalf("Unable to locate chrome cookies database")
 	}
 }
+
+func TestReadChromeCookieSchemas(t *testing.T) {
+	tz := time.UTC
+	fixtures := []string{
+		"chrome-cookies-66.sqlite",
+		"chrome-cookies-80.sqlite",
+		"chrome-cookies-96.sqlite",
+		"chrome-cookies-124.sqlite",
+	}
+
+	for _, fixture := range fixtures {
+		testCookiesPath, err := testutils.GetTestDataFilePath(fixture)
+		if err != nil {
+			t.Fatalf("Failed to load test data file")
+		}
+
+		cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+		if err != nil {
+			t.Errorf("%s: %v", fixture, err)
+			continue
+		}
+		if len(cookies) != 2 {
+			t.Errorf("%s: got %d cookies, but expected 2", fixture, len(cookies))
+			continue
+		}
+
+		session := kooky.FindCookie(".example.com", "session", cookies)
+		if session == nil {
+			t.Errorf("%s: found no session cookie", fixture)
+			continue
+		}
+		if session.Value != "abc123" || session.Path != "/" || !session.Secure || !session.HttpOnly || session.HostOnly {
+			t.Errorf("%s: unexpected session cookie %+v", fixture, session)
+		}
+		if want := time.Date(2038, 01, 17, 19, 14, 07, 0, tz); !session.Expires.Equal(want) {
+			t.Errorf("%s: want session.Expires=%v; got %v", fixture, want, session.Expires)
+		}
+		if want := time.Date(2020, 06, 01, 12, 0, 0, 0, tz); !session.Creation.Equal(want) {
+			t.Errorf("%s: want session.Creation=%v; got %v", fixture, want, session.Creation)
+		}
+		if want := time.Date(2020, 06, 02, 8, 0, 0, 0, tz); !session.LastAccess.Equal(want) {
+			t.Errorf("%s: want session.LastAccess=%v; got %v", fixture, want, session.LastAccess)
+		}
+		if session.SameSite != kooky.SameSiteLax || session.Priority != kooky.PriorityMedium || !session.Persistent {
+			t.Errorf("%s: unexpected session attributes %+v", fixture, session)
+		}
+		// Older schemas have no source_port column.
+		if want := map[string]int{"chrome-cookies-96.sqlite": 443, "chrome-cookies-124.sqlite": 443}[fixture]; session.SourcePort != want {
+			t.Errorf("%s: want session.SourcePort=%d; got %d", fixture, want, session.SourcePort)
+		}
+
+		prefs := kooky.FindCookie("www.example.com", "prefs", cookies)
+		if prefs == nil {
+			t.Errorf("%s: found no prefs cookie", fixture)
+			continue
+		}
+		if prefs.Value != "dark" || prefs.Path != "/settings" || !prefs.HostOnly || prefs.Persistent || !prefs.Expires.IsZero() {
+			t.Errorf("%s: unexpected prefs cookie %+v", fixture, prefs)
+		}
+		if prefs.SameSite != kooky.SameSiteUnspecified {
+			t.Errorf("%s: want prefs.SameSite=%v; got %v", fixture, kooky.SameSiteUnspecified, prefs.SameSite)
+		}
+	}
+}
+
+func TestReadChromeCookiesWAL(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-wal/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	wal, err := ioutil.ReadFile(testCookiesPath + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The log holds one committed transaction, updating session and adding
+	// fresh, and frames of an uncommitted one.
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value != "rotated" {
+		t.Errorf("want session cookie with value %q; got %+v", "rotated", c)
+	}
+	if c := kooky.FindCookie(".example.com", "fresh", cookies); c == nil || c.Value != "new-value" {
+		t.Errorf("want fresh cookie with value %q; got %+v", "new-value", c)
+	}
+
+	if after, err := ioutil.ReadFile(testCookiesPath + "-wal"); err != nil || !bytes.Equal(wal, after) {
+		t.Error("reading modified the write-ahead log")
+	}
+}
+
+func TestReadChromeCookiesHotJournal(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-journal/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The database holds pages of an interrupted transaction, which the
+	// journal rolls back.
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value != "abc123" {
+		t.Errorf("want session cookie with value %q; got %+v", "abc123", c)
+	}
+	if _, err := os.Stat(testCookiesPath + "-journal"); err != nil {
+		t.Error("reading removed the journal")
+	}
+}
+
+func TestReadChromeCookiesShortRows(t *testing.T) {
+	// source_scheme was added by ALTER TABLE after the session cookie was
+	// written, so its record has one column less than the table.
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-cookies-80.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.SourceScheme != kooky.SourceSchemeUnset {
+		t.Errorf("want session cookie with unset source scheme; got %+v", c)
+	}
+	if c := kooky.FindCookie("www.example.com", "prefs", cookies); c == nil || c.SourceScheme != kooky.SourceSchemeNonSecure {
+		t.Errorf("want prefs cookie with non-secure source scheme; got %+v", c)
+	}
+}
+
+func TestRecordCookieExpires(t *testing.T) {
+	columns := sqliteutil.Columns{"host_key": 0, "name": 1, "path": 2, "value": 3, "expires_utc": 4, "has_expires": 5, "is_persistent": 6}
+	tests := []struct {
+		hasExpires, isPersistent int64
+		persistent               bool
+	}{
+		{1, 1, true},
+		{0, 1, false},
+		{1, 0, false},
+	}
+	for _, test := range tests {
+		values := []interface{}{"example.com", "id", "/", "1", int64(13791842047000000), test.hasExpires, test.isPersistent}
+		cookie, _, err := recordCookie(columns, values, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cookie.Expires.IsZero() == test.persistent {
+			t.Errorf("has_expires=%d, is_persistent=%d: unexpected Expires %v", test.hasExpires, test.isPersistent, cookie.Expires)
+		}
+	}
+}
+
+func TestReadChromeCookiesDomainHash(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("v10 values are read with the default password only on Linux")
+	}
+	// A version 24 database: the value of the session cookie starts with
+	// the hash of its domain, that of the prefs cookie does not.
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-cookies-130.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader()
+	cookies, err := reader.ReadCookies(testCookiesPath, kooky.Name("session"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "abc123" {
+		t.Errorf("want session cookie with value %q; got %v", "abc123", cookies)
+	}
+
+	if _, err := reader.ReadCookies(testCookiesPath, kooky.Name("prefs")); err == nil {
+		t.Error("want reading a value without its domain hash to fail")
+	}
+}
+
+// testUnwrapper stands in for DPAPI: the fixture's Local State stores
+// the master key unprotected after the "DPAPI" prefix.
+type testUnwrapper struct{}
+
+func (testUnwrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return wrapped, nil
+}
+
+func TestReadChromeWindowsCookies(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Default/Network/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	localStatePath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Local State")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader(
+		WithKeyProvider(LocalStateKey(localStatePath, testUnwrapper{})),
+		WithOperatingSystem("windows"),
+	)
+
+	cookies, err := reader.ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value != "abc123" {
+		t.Errorf("want session cookie with value %q; got %+v", "abc123", c)
+	}
+	if c := kooky.FindCookie("www.example.com", "prefs", cookies); c == nil || c.Value != "dark" {
+		t.Errorf("want prefs cookie with value %q; got %+v", "dark", c)
+	}
+}
+
+func TestReadChromeCookiesLenient(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Default/Network/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader(
+		WithKeyProvider(StaticKey([]byte("not-the-master-key-0123456789abc"))),
+		WithOperatingSystem("windows"),
+	)
+
+	if _, err := reader.ReadCookies(testCookiesPath); err == nil {
+		t.Fatal("want strict read to fail with the wrong key")
+	}
+
+	cookies, cookieErrors, err := reader.ReadCookiesLenient(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 0 {
+		t.Errorf("got %d cookies, but expected 0", len(cookies))
+	}
+	if len(cookieErrors) != 2 {
+		t.Fatalf("got %d cookie errors, but expected 2", len(cookieErrors))
+	}
+	for _, cookieErr := range cookieErrors {
+		if cookieErr.Stage != kooky.StageDecrypt {
+			t.Errorf("want stage %q; got %q", kooky.StageDecrypt, cookieErr.Stage)
+		}
+		if cookieErr.RowID < 0 || cookieErr.Name == "" {
+			t.Errorf("want the failing cookie identified; got %+v", cookieErr)
+		}
+	}
+}
+
+func TestReadChromiumBrowserCookies(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Default/Network/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	localStatePath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Local State")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	browser := Browser{
+		Name:        "brave",
+		SafeStorage: SafeStorage{Service: "Brave Safe Storage", Account: "Brave", Applications: []string{"brave"}},
+	}
+	reader := NewBrowserCookieReader(browser,
+		WithKeyProvider(LocalStateKey(localStatePath, testUnwrapper{})),
+		WithOperatingSystem("windows"),
+	)
+
+	cookies, err := reader.ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	for _, c := range cookies {
+		if c.Browser != "brave" || c.Profile != "Default" {
+			t.Errorf("want cookie %q from brave profile Default; got browser %q, profile %q", c.Name, c.Browser, c.Profile)
+		}
+	}
+}
+
+func TestListChromeProfiles(t *testing.T) {
+	userDataDir, err := testutils.GetTestDataFilePath("chrome-windows/User Data")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	profiles, err := listProfiles(userDataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 2 {
+		t.Fatalf("got %d profiles, but expected 2", len(profiles))
+	}
+
+	want := []kooky.Profile{
+		{
+			ID:         "Default",
+			Name:       "Person 1",
+			Dir:        filepath.Join(userDataDir, "Default"),
+			CookieFile: filepath.Join(userDataDir, "Default", "Network", "Cookies"),
+		},
+		{
+			ID:         "Profile 1",
+			Name:       "Work",
+			Email:      "jane@example.com",
+			Dir:        filepath.Join(userDataDir, "Profile 1"),
+			CookieFile: filepath.Join(userDataDir, "Profile 1", "Cookies"),
+		},
+	}
+	for i := range want {
+		if profiles[i] != want[i] {
+			t.Errorf("want profile %+v; got %+v", want[i], profiles[i])
+		}
+	}
+
+	if p := kooky.FindProfile("Work", profiles); p == nil || p.ID != "Profile 1" {
+		t.Errorf("want profile %q found by name; got %+v", "Profile 1", p)
+	}
+}
+
+func TestLocalStateMasterKey(t *testing.T) {
+	localStatePath, err := testutils.GetTestDataFilePath("chrome-windows/User Data/Local State")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	localState, err := ReadLocalState(localStatePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := localState.MasterKey(testUnwrapper{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "kooky-test-master-key-0123456789"; string(key) != want {
+		t.Errorf("want master key %q; got %q", want, key)
+	}
+}
+
+func TestCachedKeyProvider(t *testing.T) {
+	calls := 0
+	provider := CachedKeyProvider(KeyProviderFunc(func() (Key, error) {
+		calls++
+		if calls == 1 {
+			return Key{}, errNoKey
+		}
+		return Key{Password: []byte("secret")}, nil
+	}))
+
+	// A failed lookup is remembered too.
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Key(); err != errNoKey {
+			t.Errorf("want %v; got %v", errNoKey, err)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("want 1 lookup; got %d", calls)
+	}
+}
+
+func TestReadChromeCookiesKeyringUnavailable(t *testing.T) {
+	filename, cleanup := copyTestDatabase(t, "chrome-cookies-124.sqlite")
+	defer cleanup()
+
+	// "v11" values encrypted with the "v10" password, as a fallback
+	// decrypts them.
+	cookies := []*kooky.Cookie{
+		{Name: "first", Value: "one", Domain: "example.org"},
+		{Name: "second", Value: "two", Domain: "example.org"},
+	}
+	writer := NewCookieWriter(WithOperatingSystem("linux"), WithKeyProvider(StaticPassword(linuxV10Password)))
+	if err := writer.WriteCookies(filename, cookies); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	unavailable := KeyProviderFunc(func() (Key, error) {
+		calls++
+		return Key{}, errors.New("keyring unavailable")
+	})
+
+	reader := NewCookieReader(WithOperatingSystem("linux"), WithKeyProvider(unavailable), WithoutV10Fallback())
+	_, cookieErrors, err := reader.ReadCookiesLenient(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookieErrors) != 2 {
+		t.Errorf("got %d cookie errors, but expected 2", len(cookieErrors))
+	}
+	if calls != 1 {
+		t.Errorf("want 1 key lookup; got %d", calls)
+	}
+
+	reader = NewCookieReader(WithOperatingSystem("linux"), WithKeyProvider(unavailable))
+	read, err := reader.ReadCookies(filename, kooky.Name("second"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(read) != 1 || read[0].Value != "two" {
+		t.Errorf("want second cookie with value %q; got %v", "two", read)
+	}
+}
+
+func TestReadChromeCookiesFS(t *testing.T) {
+	testDataDir, err := testutils.GetTestDataFilePath(".")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	fsys := os.DirFS(testDataDir)
+
+	cookies, err := NewCookieReader().ReadCookiesFS(fsys, "chrome-wal/Cookies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The write-ahead log is read from fsys as well.
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+	c := kooky.FindCookie(".example.com", "session", cookies)
+	if c == nil || c.Value != "rotated" {
+		t.Fatalf("want session cookie with value %q; got %+v", "rotated", c)
+	}
+	if c.Profile != "chrome-wal" || c.File != "chrome-wal/Cookies" {
+		t.Errorf("want provenance within fsys; got profile %q, file %q", c.Profile, c.File)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(testDataDir, "chrome-wal", "Cookies"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err = NewCookieReader().ReadCookiesFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without its write-ahead log, the database holds the checkpointed cookies.
+	if len(cookies) != 2 {
+		t.Fatalf("got %d cookies, but expected 2", len(cookies))
+	}
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value == "rotated" || c.File != "" || c.Profile != "" {
+		t.Errorf("want checkpointed session cookie without provenance; got %+v", c)
+	}
+}
+
+// copyTestDatabase copies a fixture into a temporary directory, which the
+// returned function removes.
+func copyTestDatabase(t *testing.T, fixture string) (string, func()) {
+	testCookiesPath, err := testutils.GetTestDataFilePath(fixture)
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	data, err := ioutil.ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "kooky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "Cookies")
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return filename, func() { os.RemoveAll(dir) }
+}
+
+func TestWriteChromeCookies(t *testing.T) {
+	fixtures := []string{
+		"chrome-cookies-66.sqlite",
+		"chrome-cookies-80.sqlite",
+		"chrome-cookies-96.sqlite",
+		"chrome-cookies-124.sqlite",
+	}
+	expires := time.Date(2030, 1, 2, 3, 4, 5, 123456000, time.UTC)
+
+	for _, fixture := range fixtures {
+		t.Run(fixture, func(t *testing.T) {
+			filename, cleanup := copyTestDatabase(t, fixture)
+			defer cleanup()
+
+			writer := NewCookieWriter(WithOperatingSystem("linux"))
+			err := writer.WriteCookies(filename, []*kooky.Cookie{
+				{Name: "added", Value: "fresh", Domain: "example.org", Path: "/", Expires: expires, Persistent: true, Secure: true, HostOnly: true, SameSite: kooky.SameSiteStrict},
+				{Name: "session", Value: "rotated", Domain: "example.com", Path: "/", Expires: expires, Persistent: true, HttpOnly: true},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			reader := NewCookieReader(WithOperatingSystem("linux"))
+			cookies, err := reader.ReadAllCookies(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cookies) != 3 {
+				t.Fatalf("got %d cookies, but expected 3", len(cookies))
+			}
+
+			added := kooky.FindCookie("example.org", "added", cookies)
+			if added == nil {
+				t.Fatal("found no added cookie")
+			}
+			if added.Value != "fresh" || !added.HostOnly || !added.Secure || !added.Persistent || !added.Expires.Equal(expires) {
+				t.Errorf("unexpected added cookie %+v", added)
+			}
+
+			// The session cookie is replaced, but keeps its creation time.
+			session := kooky.FindCookie(".example.com", "session", cookies)
+			if session == nil {
+				t.Fatal("found no session cookie")
+			}
+			if session.Value != "rotated" || session.Secure || !session.HttpOnly || !session.Expires.Equal(expires) {
+				t.Errorf("unexpected session cookie %+v", session)
+			}
+			if want := time.Date(2020, 06, 01, 12, 0, 0, 0, time.UTC); !session.Creation.Equal(want) {
+				t.Errorf("want session.Creation=%v; got %v", want, session.Creation)
+			}
+
+			deleted, err := writer.DeleteCookies(filename, kooky.DomainHasSuffix("example.com"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if deleted != 2 {
+				t.Errorf("deleted %d cookies, but expected 2", deleted)
+			}
+			cookies, err = reader.ReadAllCookies(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cookies) != 1 || cookies[0].Name != "added" {
+				t.Errorf("want only the added cookie left; got %v", cookies)
+			}
+			integrityCheck(t, filename)
+		})
+	}
+}
+
+func TestWriteChromeCookiesMacOS(t *testing.T) {
+	// A database written by Chrome itself, with a partial index.
+	filename, cleanup := copyTestDatabase(t, "small-chome-cookie-db.sqlite")
+	defer cleanup()
+
+	options := []Option{WithKeyProvider(StaticPassword([]byte("ChromeSafeStoragePasswrd"))), WithOperatingSystem("darwin")}
+	written := []*kooky.Cookie{
+		{Name: "user", Value: "replaced", Domain: "news.ycombinator.com", Path: "/", HostOnly: true},
+	}
+	const bulk = 3000
+	for i := 0; i < bulk; i++ {
+		written = append(written, &kooky.Cookie{Name: fmt.Sprintf("bulk%d", i), Value: "v", Domain: "example.com", Path: "/"})
+	}
+
+	start := time.Now()
+	if err := NewCookieWriter(options...).WriteCookies(filename, written); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("writing %d cookies took %v", len(written), elapsed)
+	}
+
+	cookies, err := NewCookieReader(options...).ReadAllCookies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != bulk+1 {
+		t.Fatalf("got %d cookies, but expected %d", len(cookies), bulk+1)
+	}
+	if c := kooky.FindCookie("news.ycombinator.com", "user", cookies); c == nil || c.Value != "replaced" {
+		t.Errorf("want the user cookie replaced; got %+v", c)
+	}
+	integrityCheck(t, filename)
+}
+
+func TestWriteChromeCookiesEncrypted(t *testing.T) {
+	tests := []struct {
+		name            string
+		operatingSystem string
+		keys            KeyProvider
+		version         string
+	}{
+		{"linux", "linux", StaticPassword([]byte("keyring password")), ""},
+		{"darwin", "darwin", StaticPassword([]byte("ChromeSafeStoragePasswrd")), ""},
+		{"windows", "windows", StaticKey([]byte("0123456789abcdef0123456789abcdef")), ""},
+		{"hashed domain", "windows", StaticKey([]byte("0123456789abcdef0123456789abcdef")), "24"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			filename, cleanup := copyTestDatabase(t, "chrome-cookies-124.sqlite")
+			defer cleanup()
+			if test.version != "" {
+				setDatabaseVersion(t, filename, test.version)
+			}
+
+			options := []Option{WithOperatingSystem(test.operatingSystem), WithKeyProvider(test.keys)}
+			cookie := &kooky.Cookie{Name: "secret", Value: "s3cr3t", Domain: "example.net", Path: "/"}
+			if err := NewCookieWriter(options...).WriteCookies(filename, []*kooky.Cookie{cookie}); err != nil {
+				t.Fatal(err)
+			}
+
+			cookies, err := NewCookieReader(options...).ReadAllCookies(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c := kooky.FindCookie(".example.net", "secret", cookies); c == nil || c.Value != "s3cr3t" {
+				t.Errorf("want secret cookie with value %q; got %+v", "s3cr3t", c)
+			}
+			integrityCheck(t, filename)
+
+			// A value encrypted with another key does not decrypt.
+			wrongKey := WithKeyProvider(StaticPassword([]byte("wrong")))
+			if test.operatingSystem == "windows" {
+				wrongKey = WithKeyProvider(StaticKey([]byte("fedcba9876543210fedcba9876543210")))
+			}
+			if _, err := NewCookieReader(WithOperatingSystem(test.operatingSystem), wrongKey).ReadCookies(filename, kooky.Name("secret")); err == nil {
+				t.Error("want reading with the wrong key to fail")
+			}
+		})
+	}
+}
+
+func TestReadChromeCookiesDomainHashMismatch(t *testing.T) {
+	filename, cleanup := copyTestDatabase(t, "chrome-cookies-124.sqlite")
+	defer cleanup()
+
+	options := []Option{WithOperatingSystem("windows"), WithKeyProvider(StaticKey([]byte("0123456789abcdef0123456789abcdef")))}
+	cookie := &kooky.Cookie{Name: "secret", Value: "a value longer than the 32 bytes of a hash", Domain: "example.net", Path: "/"}
+	if err := NewCookieWriter(options...).WriteCookies(filename, []*kooky.Cookie{cookie}); err != nil {
+		t.Fatal(err)
+	}
+	// The value was written without the hash its database now calls for.
+	setDatabaseVersion(t, filename, "24")
+
+	reader := NewCookieReader(options...)
+	if _, err := reader.ReadCookies(filename, kooky.Name("secret")); err == nil {
+		t.Error("want reading a value without its domain hash to fail")
+	}
+	cookies, cookieErrors, err := reader.ReadCookiesLenient(filename, kooky.Name("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 0 || len(cookieErrors) != 1 || cookieErrors[0].Stage != kooky.StageDecrypt {
+		t.Errorf("want 1 decrypt error; got %v, %v", cookies, cookieErrors)
+	}
+}
+
+// setDatabaseVersion sets the version in the meta table of a cookie
+// database.
+func setDatabaseVersion(t *testing.T, filename string, version string) {
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("UPDATE meta SET value = ? WHERE key = 'version'", version); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// integrityCheck fails t unless sqlite finds the database filename intact.
+func integrityCheck(t *testing.T, filename string) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var result string
+	if err := db.QueryRow("PRAGMA integrity_check").Scan(&result); err != nil {
+		t.Fatal(err)
+	}
+	if result != "ok" {
+		t.Errorf("want an intact database; got %s", result)
+	}
+}
+
+func TestChromeTimestamp(t *testing.T) {
+	for _, date := range []time.Time{
+		time.Date(2030, 1, 1, 0, 0, 0, 123456000, time.UTC),
+		time.Date(9999, 12, 31, 23, 59, 59, 0, time.UTC),
+	} {
+		if got := chromeCookieDate(chromeTimestamp(date)); !got.Equal(date) {
+			t.Errorf("want %v after a round trip; got %v", date, got)
+		}
+	}
+	if got := chromeTimestamp(time.Time{}); got != 0 {
+		t.Errorf("want 0 for the zero time; got %d", got)
+	}
+}
+
+func TestWriteChromeCookiesWAL(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("chrome-wal/Cookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	filename, cleanup := copyTestDatabase(t, "chrome-wal/Cookies")
+	defer cleanup()
+	wal, err := ioutil.ReadFile(testCookiesPath + "-wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filename+"-wal", wal, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cookie := &kooky.Cookie{Name: "added", Value: "x", Domain: "example.org", HostOnly: true}
+	if err := NewCookieWriter(WithOperatingSystem("linux")).WriteCookies(filename, []*kooky.Cookie{cookie}); err != nil {
+		t.Fatal(err)
+	}
+
+	// sqlite checkpoints the committed changes of the log, and removes it
+	// once the writer closes the database.
+	if _, err := os.Stat(filename + "-wal"); !os.IsNotExist(err) {
+		t.Errorf("want the write-ahead log removed; got %v", err)
+	}
+	if files, err := ioutil.ReadDir(filepath.Dir(filename)); err != nil || len(files) != 1 {
+		t.Errorf("want only the database left; got %d files, %v", len(files), err)
+	}
+	cookies, err := NewCookieReader(WithOperatingSystem("linux")).ReadAllCookies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 4 {
+		t.Fatalf("got %d cookies, but expected 4", len(cookies))
+	}
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value != "rotated" {
+		t.Errorf("want session cookie with value %q; got %+v", "rotated", c)
+	}
+	integrityCheck(t, filename)
+}
+
+func TestWriteChromeCookiesLocked(t *testing.T) {
+	filename, cleanup := copyTestDatabase(t, "chrome-cookies-124.sqlite")
+	defer cleanup()
+
+	// Chrome keeps its lock on the database for as long as it has it open,
+	// as does a connection in exclusive locking mode once it has read it.
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	var count int
+	if _, err := db.Exec("PRAGMA locking_mode=EXCLUSIVE"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT count(*) FROM cookies").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+
+	writer := NewCookieWriter(WithOperatingSystem("linux"))
+	cookies := []*kooky.Cookie{{Name: "added", Value: "x", Domain: "example.org", HostOnly: true}}
+	if err := writer.WriteCookies(filename, cookies); !errors.Is(err, ErrLocked) {
+		t.Errorf("want %v writing while the database is open; got %v", ErrLocked, err)
+	}
+	if _, err := writer.DeleteCookies(filename); !errors.Is(err, ErrLocked) {
+		t.Errorf("want %v deleting while the database is open; got %v", ErrLocked, err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteCookies(filename, cookies); err != nil {
+		t.Fatal(err)
+	}
+	integrityCheck(t, filename)
+}
+
+func TestWriteChromeCookiesProfileInUse(t *testing.T) {
+	filename, cleanup := copyTestDatabase(t, "chrome-cookies-124.sqlite")
+	defer cleanup()
+
+	// The running browser links SingletonLock in its user data directory
+	// to its host and process, which need not exist.
+	userData := filepath.Dir(filename)
+	if err := ioutil.WriteFile(filepath.Join(userData, "Local State"), []byte("{}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	lock := filepath.Join(userData, "SingletonLock")
+	if err := os.Symlink("kooky-12345", lock); err != nil {
+		t.Skip(err)
+	}
+
+	writer := NewCookieWriter(WithOperatingSystem("linux"))
+	cookies := []*kooky.Cookie{{Name: "added", Value: "x", Domain: "example.org", HostOnly: true}}
+	if err := writer.WriteCookies(filename, cookies); !errors.Is(err, ErrLocked) {
+		t.Errorf("want %v writing while the profile is in use; got %v", ErrLocked, err)
+	}
+	if _, err := writer.DeleteCookies(filename); !errors.Is(err, ErrLocked) {
+		t.Errorf("want %v deleting while the profile is in use; got %v", ErrLocked, err)
+	}
+
+	if err := os.Remove(lock); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteCookies(filename, cookies); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBrowserDefaults(t *testing.T) {
+	oldHomeDir := homeDir
+	defer func() { homeDir = oldHomeDir }()
+	homeDir = func() (string, error) { return "/home/kooky", nil }
+
+	tests := []struct {
+		name        string
+		linuxCookie string
+		darwinPath  string
+		service     string
+		account     string
+		application string
+	}{
+		{"chrome", ".config/google-chrome/Default/Cookies", "Library/Application Support/Google/Chrome", "Chrome Safe Storage", "Chrome", "chrome"},
+		{"chromium", ".config/chromium/Default/Cookies", "Library/Application Support/Chromium", "Chromium Safe Storage", "Chromium", "chromium"},
+		{"brave", ".config/BraveSoftware/Brave-Browser/Default/Cookies", "Library/Application Support/BraveSoftware/Brave-Browser", "Brave Safe Storage", "Brave", "brave"},
+		{"edge", ".config/microsoft-edge/Default/Cookies", "Library/Application Support/Microsoft Edge", "Microsoft Edge Safe Storage", "Microsoft Edge", "microsoft-edge"},
+		{"opera", ".config/opera/Cookies", "Library/Application Support/com.operasoftware.Opera", "Opera Safe Storage", "Opera", "opera"},
+		{"vivaldi", ".config/vivaldi/Default/Cookies", "Library/Application Support/Vivaldi", "Vivaldi Safe Storage", "Vivaldi", "vivaldi"},
+		{"yandex", ".config/yandex-browser/Default/Cookies", "Library/Application Support/Yandex/YandexBrowser", "Yandex Safe Storage", "Yandex", "yandex-browser"},
+	}
+
+	for _, test := range tests {
+		browser, ok := LookupBrowser(test.name)
+		if !ok || browser.Name != test.name {
+			t.Errorf("%s: want a browser of that name; got %q, %v", test.name, browser.Name, ok)
+			continue
+		}
+		reader := NewBrowserCookieReader(browser)
+		cookiePath, err := reader.GetDefaultCookieFilePath("linux")
+		if err != nil {
+			t.Errorf("%s: %v", browser.Name, err)
+			continue
+		}
+		if want := filepath.Join("/home/kooky", test.linuxCookie); cookiePath != want {
+			t.Errorf("%s: want cookie file %q; got %q", browser.Name, want, cookiePath)
+		}
+		if path, ok := browser.UserDataPaths.Get("darwin"); !ok || path != test.darwinPath {
+			t.Errorf("%s: want macOS user data directory %q; got %q", browser.Name, test.darwinPath, path)
+		}
+		for _, operatingSystem := range []string{"windows", "darwin", "linux"} {
+			if _, err := reader.GetDefaultInstallPath(operatingSystem); err != nil {
+				t.Errorf("%s: no install path on %s", browser.Name, operatingSystem)
+			}
+			if _, ok := browser.UserDataPaths.Get(operatingSystem); !ok {
+				t.Errorf("%s: no user data directory on %s", browser.Name, operatingSystem)
+			}
+		}
+
+		storage := browser.SafeStorage
+		if storage.Service != test.service || storage.Account != test.account || len(storage.Applications) == 0 || storage.Applications[0] != test.application {
+			t.Errorf("%s: unexpected Safe Storage %+v", browser.Name, storage)
+		}
+	}
+
+	// Changing a browser does not change the next one looked up.
+	browser, _ := LookupBrowser("brave")
+	browser.SafeStorage.Applications[0] = "changed"
+	if browser, _ := LookupBrowser("brave"); browser.SafeStorage.Applications[0] != "brave" {
+		t.Errorf("want a new Browser from each lookup; got %+v", browser.SafeStorage)
+	}
+	if _, ok := LookupBrowser("netscape"); ok {
+		t.Error("want no browser named netscape")
+	}
+}
@@ -4,6 +4,7 @@ package chrome
 // https://play.golang.org/p/fknP9AuLU-
 
 import (
+	"errors"
 	"syscall"
 	"unsafe"
 )
@@ -44,7 +45,7 @@ func (b *dataBlob) toByteArray() []byte {
 	return d
 }
 
-func decrypt(data []byte) ([]byte, error) {
+func dpapiDecrypt(data []byte) ([]byte, error) {
 	var outblob dataBlob
 	r, _, err := procDecryptData.Call(
 		uintptr(unsafe.Pointer(newBlob(data))),
@@ -65,14 +66,22 @@ func decrypt(data []byte) ([]byte, error) {
 	return outblob.toByteArray(), nil
 }
 
-func decryptValue(encrypted []byte) (string, error) {
-	s, err := decrypt(encrypted)
-	if err != nil {
-		return ``, err
-	}
-	return string(s), nil
+// dpapiUnwrapper unwraps the Local State master key with DPAPI, which only
+// succeeds for the Windows user who ran Chrome.
+type dpapiUnwrapper struct{}
+
+func (dpapiUnwrapper) UnwrapKey(wrapped []byte) ([]byte, error) {
+	return dpapiDecrypt(wrapped)
+}
+
+func defaultKeyUnwrapper() KeyUnwrapper {
+	return dpapiUnwrapper{}
 }
 
-func setChromeKeychainPassword(password []byte) []byte {
-	return password
+// KeyringPassword returns a KeyProvider that always fails: Chrome on
+// Windows has no Safe Storage password, use LocalStateKey instead.
+func KeyringPassword(storage SafeStorage) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		return Key{}, errors.New("no Safe Storage password on windows")
+	})
 }
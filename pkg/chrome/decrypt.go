@@ -0,0 +1,227 @@
+package chrome
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// https://cs.chromium.org/chromium/src/components/os_crypt/os_crypt_mac.mm
+// https://cs.chromium.org/chromium/src/components/os_crypt/os_crypt_linux.cc
+// https://cs.chromium.org/chromium/src/components/os_crypt/os_crypt_win.cc
+
+const (
+	salt   = "saltysalt"
+	iv     = "                "
+	length = 16
+
+	macIterations   = 1003
+	linuxIterations = 1
+
+	gcmNonceLength = 12
+)
+
+// linuxV10Password is the hardcoded password of "v10" cookies on Linux.
+var linuxV10Password = []byte("peanuts")
+
+// decrypter decrypts the encrypted_value column of one cookies database,
+// the way Chrome on operatingSystem encrypted it.
+type decrypter struct {
+	operatingSystem string
+	keys            KeyProvider
+
+	// v10Fallback decrypts "v11" values on Linux with the "v10" password
+	// if the key provider fails.
+	v10Fallback bool
+
+	key    *Key
+	keyErr error
+
+	// cbcKeys are the keys derived from passwords, by iterations and
+	// password; deriving one takes long enough to matter per cookie.
+	cbcKeys map[string][]byte
+}
+
+func (d *decrypter) decrypt(encrypted []byte) (string, error) {
+	if len(encrypted) == 0 {
+		return "", errors.New("empty encrypted value")
+	}
+
+	if len(encrypted) <= 3 {
+		return "", fmt.Errorf("too short encrypted value (%d<=3)", len(encrypted))
+	}
+
+	version := string(encrypted[:3])
+	switch version {
+	case "v10", "v11":
+	case "v20":
+		return "", errors.New("app-bound encrypted (v20) values are not supported")
+	default:
+		// Values written before Chrome 80 on Windows are plain DPAPI blobs.
+		if d.operatingSystem == "windows" {
+			plainText, err := dpapiDecrypt(encrypted)
+			if err != nil {
+				return "", err
+			}
+			return string(plainText), nil
+		}
+		version = "v10"
+	}
+	encrypted = encrypted[3:]
+
+	switch d.operatingSystem {
+	case "windows":
+		key, err := d.getKey()
+		if err != nil {
+			return "", err
+		}
+		if len(key.AESKey) == 0 {
+			return "", errors.New("key provider supplied no AES key")
+		}
+		return decryptAESGCM(key.AESKey, encrypted)
+	case "darwin":
+		key, err := d.getKey()
+		if err != nil {
+			return "", err
+		}
+		aesKey, err := d.cbcKey(key, macIterations)
+		if err != nil {
+			return "", err
+		}
+		return decryptAESCBC(aesKey, encrypted)
+	default:
+		key := Key{Password: linuxV10Password}
+		if version == "v11" {
+			k, err := d.getKey()
+			if err != nil && !d.v10Fallback {
+				return "", err
+			}
+			if err == nil {
+				key = k
+			}
+		}
+		aesKey, err := d.cbcKey(key, linuxIterations)
+		if err != nil {
+			return "", err
+		}
+		return decryptAESCBC(aesKey, encrypted)
+	}
+}
+
+// getKey asks the key provider for the key once per database, remembering
+// a failed lookup as well.
+func (d *decrypter) getKey() (Key, error) {
+	if d.key != nil {
+		return *d.key, nil
+	}
+	if d.keyErr != nil {
+		return Key{}, d.keyErr
+	}
+	if d.keys == nil {
+		return Key{}, errNoKey
+	}
+
+	key, err := d.keys.Key()
+	if err != nil {
+		d.keyErr = err
+		return Key{}, err
+	}
+
+	d.key = &key
+	return key, nil
+}
+
+// cbcKey returns key.cbcKey(iterations), deriving the key of each password
+// once.
+func (d *decrypter) cbcKey(key Key, iterations int) ([]byte, error) {
+	if len(key.AESKey) > 0 || len(key.Password) == 0 {
+		return key.cbcKey(iterations)
+	}
+	id := fmt.Sprintf("%d:%s", iterations, key.Password)
+	if aesKey, ok := d.cbcKeys[id]; ok {
+		return aesKey, nil
+	}
+	aesKey, err := key.cbcKey(iterations)
+	if err != nil {
+		return nil, err
+	}
+	if d.cbcKeys == nil {
+		d.cbcKeys = map[string][]byte{}
+	}
+	d.cbcKeys[id] = aesKey
+	return aesKey, nil
+}
+
+// cbcKey returns the AES-128 key of the macOS and Linux schemes, deriving
+// it from the password if no raw key is set.
+func (key Key) cbcKey(iterations int) ([]byte, error) {
+	if len(key.AESKey) > 0 {
+		return key.AESKey, nil
+	}
+	if len(key.Password) == 0 {
+		return nil, errNoKey
+	}
+	return pbkdf2.Key(key.Password, []byte(salt), iterations, length, sha1.New), nil
+}
+
+func decryptAESCBC(key []byte, encrypted []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(encrypted)%length != 0 {
+		return "", fmt.Errorf("encrypted data block length is not a multiple of %d", length)
+	}
+
+	decrypted := make([]byte, len(encrypted))
+	cbc := cipher.NewCBCDecrypter(block, []byte(iv))
+	cbc.CryptBlocks(decrypted, encrypted)
+
+	plainText, err := aesStripPadding(decrypted)
+	if err != nil {
+		return "", err
+	}
+	return string(plainText), nil
+}
+
+// decryptAESGCM decrypts a value laid out as a 12 byte nonce followed by
+// the ciphertext and its 16 byte authentication tag.
+func decryptAESGCM(key []byte, encrypted []byte) (string, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	if len(encrypted) < gcmNonceLength+gcm.Overhead() {
+		return "", fmt.Errorf("too short AES-GCM encrypted value (%d)", len(encrypted))
+	}
+
+	plainText, err := gcm.Open(nil, encrypted[:gcmNonceLength], encrypted[gcmNonceLength:], nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plainText), nil
+}
+
+// In the padding scheme the last <padding length> bytes
+// have a value equal to the padding length, always in (1,16]
+func aesStripPadding(data []byte) ([]byte, error) {
+	if len(data) == 0 || len(data)%length != 0 {
+		return nil, fmt.Errorf("decrypted data block length is not a multiple of %d", length)
+	}
+	paddingLen := int(data[len(data)-1])
+	if paddingLen > 16 {
+		return nil, fmt.Errorf("invalid last block padding length: %d", paddingLen)
+	}
+	return data[:len(data)-paddingLen], nil
+}
@@ -0,0 +1,18 @@
+// +build !windows
+
+package chrome
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// dpapiDecrypt is only available on Windows; DPAPI keys are bound to the
+// Windows user account.
+func dpapiDecrypt(encrypted []byte) ([]byte, error) {
+	return nil, fmt.Errorf("DPAPI decryption not available on %q", runtime.GOOS)
+}
+
+func defaultKeyUnwrapper() KeyUnwrapper {
+	return nil
+}
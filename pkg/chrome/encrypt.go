@@ -0,0 +1,104 @@
+package chrome
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// encrypter encrypts cookie values the way Chrome on operatingSystem does,
+// for its decrypter to read back. On Linux, values are "v11" values if
+// there is a key provider and "v10" values otherwise.
+type encrypter struct {
+	decrypter
+
+	// hashDomain prefixes values with the hash of their domain, as in
+	// databases of domainHashVersion.
+	hashDomain bool
+}
+
+func (e *encrypter) encrypt(value string, domain string) ([]byte, error) {
+	plainText := []byte(value)
+	if e.hashDomain {
+		hash := sha256.Sum256([]byte(domain))
+		plainText = append(hash[:], plainText...)
+	}
+
+	switch e.operatingSystem {
+	case "windows":
+		key, err := e.getKey()
+		if err != nil {
+			return nil, err
+		}
+		if len(key.AESKey) == 0 {
+			return nil, errors.New("key provider supplied no AES key")
+		}
+		return encryptAESGCM(key.AESKey, plainText)
+	case "darwin":
+		key, err := e.getKey()
+		if err != nil {
+			return nil, err
+		}
+		aesKey, err := e.cbcKey(key, macIterations)
+		if err != nil {
+			return nil, err
+		}
+		return encryptAESCBC("v10", aesKey, plainText)
+	default:
+		version, key := "v10", Key{Password: linuxV10Password}
+		if e.keys != nil {
+			k, err := e.getKey()
+			if err != nil {
+				return nil, err
+			}
+			version, key = "v11", k
+		}
+		aesKey, err := e.cbcKey(key, linuxIterations)
+		if err != nil {
+			return nil, err
+		}
+		return encryptAESCBC(version, aesKey, plainText)
+	}
+}
+
+func encryptAESCBC(version string, key []byte, plainText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding, which aesStripPadding removes.
+	paddingLen := length - len(plainText)%length
+	padded := append(append([]byte{}, plainText...), make([]byte, paddingLen)...)
+	for i := len(plainText); i < len(padded); i++ {
+		padded[i] = byte(paddingLen)
+	}
+
+	encrypted := make([]byte, len(padded))
+	cbc := cipher.NewCBCEncrypter(block, []byte(iv))
+	cbc.CryptBlocks(encrypted, padded)
+	return append([]byte(version), encrypted...), nil
+}
+
+// encryptAESGCM encrypts a value as a "v10" value laid out as decryptAESGCM
+// expects, with a random nonce.
+func encryptAESGCM(key []byte, plainText []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcmNonceLength)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	encrypted := append([]byte("v10"), nonce...)
+	return gcm.Seal(encrypted, nonce, plainText, nil), nil
+}
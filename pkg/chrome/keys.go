@@ -0,0 +1,137 @@
+package chrome
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"github.com/kgoins/kooky/internal/storefs"
+)
+
+// Key is the secret Chrome encrypts cookie values with.
+type Key struct {
+	// Password is the Safe Storage password kept in the macOS Keychain or
+	// the Linux keyring, from which the AES key is derived.
+	Password []byte
+
+	// AESKey is a raw AES key, used as is: the derived AES-128 key on macOS
+	// and Linux, or the AES-256 master key from Local State on Windows.
+	// It takes precedence over Password.
+	AESKey []byte
+}
+
+// KeyProvider supplies the key to decrypt Chrome cookie values with.
+type KeyProvider interface {
+	Key() (Key, error)
+}
+
+// KeyProviderFunc adapts a function to the KeyProvider interface.
+type KeyProviderFunc func() (Key, error)
+
+// Key calls f.
+func (f KeyProviderFunc) Key() (Key, error) {
+	return f()
+}
+
+// SafeStorage names the entry Chrome keeps its Safe Storage password in.
+type SafeStorage struct {
+	// Service and Account of the macOS Keychain generic password.
+	Service string
+	Account string
+
+	// Applications are the values of the "application" attribute of the
+	// Linux Secret Service item, tried in order.
+	Applications []string
+}
+
+// StaticPassword returns a KeyProvider supplying a known Safe Storage password.
+func StaticPassword(password []byte) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		return Key{Password: password}, nil
+	})
+}
+
+// StaticKey returns a KeyProvider supplying a known raw AES key.
+func StaticKey(aesKey []byte) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		return Key{AESKey: aesKey}, nil
+	})
+}
+
+// EnvPassword returns a KeyProvider reading the Safe Storage password from
+// the environment variable name.
+func EnvPassword(name string) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		password, found := os.LookupEnv(name)
+		if !found {
+			return Key{}, fmt.Errorf("environment variable %s not set", name)
+		}
+		return Key{Password: []byte(password)}, nil
+	})
+}
+
+// FilePassword returns a KeyProvider reading the Safe Storage password from
+// a file. A trailing newline is ignored.
+func FilePassword(filename string) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		password, err := ioutil.ReadFile(filename)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Password: bytes.TrimRight(password, "\r\n")}, nil
+	})
+}
+
+// LocalStateKey returns a KeyProvider reading the Windows master key from
+// the Local State file at filename, unwrapping it with unwrapper.
+func LocalStateKey(filename string, unwrapper KeyUnwrapper) KeyProvider {
+	return localStateKey(storefs.OS(), filename, unwrapper)
+}
+
+func localStateKey(files storefs.FS, name string, unwrapper KeyUnwrapper) KeyProvider {
+	return KeyProviderFunc(func() (Key, error) {
+		localState, err := readLocalState(files, name)
+		if err != nil {
+			return Key{}, err
+		}
+		masterKey, err := localState.MasterKey(unwrapper)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{AESKey: masterKey}, nil
+	})
+}
+
+type cachedKeyProvider struct {
+	provider KeyProvider
+
+	mu     sync.Mutex
+	looked bool
+	key    Key
+	err    error
+}
+
+// CachedKeyProvider returns a KeyProvider that asks provider for the key
+// once and remembers the result, so that an unavailable keyring is not
+// queried again either. It is safe for concurrent use.
+func CachedKeyProvider(provider KeyProvider) KeyProvider {
+	return &cachedKeyProvider{provider: provider}
+}
+
+func (c *cachedKeyProvider) Key() (Key, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.looked {
+		c.key, c.err = c.provider.Key()
+		c.looked = true
+	}
+	return c.key, c.err
+}
+
+// errNoKey is returned when a key provider supplied neither a password nor
+// an AES key.
+var errNoKey = errors.New("key provider supplied no key")
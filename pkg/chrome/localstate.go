@@ -0,0 +1,97 @@
+package chrome
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/kgoins/kooky/internal/storefs"
+)
+
+// dpapiPrefix marks the os_crypt master key as protected with DPAPI.
+const dpapiPrefix = "DPAPI"
+
+// localStateFile is the name of the file in Chrome's user data directory
+// that holds browser-wide state, including the cookie encryption key.
+const localStateFile = "Local State"
+
+// LocalState is the subset of Chrome's "Local State" file used by kooky.
+type LocalState struct {
+	OSCrypt struct {
+		EncryptedKey string `json:"encrypted_key"`
+	} `json:"os_crypt"`
+
+	Profile struct {
+		// InfoCache maps profile directory names to their details.
+		InfoCache map[string]ProfileInfo `json:"info_cache"`
+	} `json:"profile"`
+}
+
+// ProfileInfo describes a profile in the Local State file.
+type ProfileInfo struct {
+	Name     string `json:"name"`
+	UserName string `json:"user_name"`
+	GAIAName string `json:"gaia_name"`
+}
+
+// KeyUnwrapper unwraps the os_crypt master key stored in Local State.
+// On Windows the key is protected with DPAPI for the current user.
+type KeyUnwrapper interface {
+	UnwrapKey(wrapped []byte) ([]byte, error)
+}
+
+// ReadLocalState parses the Local State file at filename.
+func ReadLocalState(filename string) (*LocalState, error) {
+	return readLocalState(storefs.OS(), filename)
+}
+
+func readLocalState(files storefs.FS, name string) (*LocalState, error) {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var localState LocalState
+	if err := json.Unmarshal(data, &localState); err != nil {
+		return nil, err
+	}
+	return &localState, nil
+}
+
+// MasterKey returns the AES-256 key Chrome on Windows encrypts cookies
+// with, unwrapping it with unwrapper.
+func (localState *LocalState) MasterKey(unwrapper KeyUnwrapper) ([]byte, error) {
+	if localState.OSCrypt.EncryptedKey == "" {
+		return nil, errors.New("no os_crypt.encrypted_key in Local State")
+	}
+
+	encryptedKey, err := base64.StdEncoding.DecodeString(localState.OSCrypt.EncryptedKey)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(string(encryptedKey), dpapiPrefix) {
+		return nil, errors.New("os_crypt.encrypted_key is not DPAPI protected")
+	}
+
+	if unwrapper == nil {
+		return nil, errors.New("no key unwrapper for the os_crypt master key")
+	}
+	return unwrapper.UnwrapKey(encryptedKey[len(dpapiPrefix):])
+}
+
+// findLocalState returns the path of the Local State file belonging to a
+// cookie file, which lives in either "<User Data>/<Profile>/Cookies" or
+// "<User Data>/<Profile>/Network/Cookies".
+func findLocalState(files storefs.FS, cookieFile string) (string, error) {
+	dir := files.Dir(cookieFile)
+	for i := 0; i < 3; i++ {
+		path := files.Join(dir, localStateFile)
+		if files.Exists(path) {
+			return path, nil
+		}
+		dir = files.Dir(dir)
+	}
+
+	return "", errors.New("unable to locate Local State for " + cookieFile)
+}
@@ -0,0 +1,126 @@
+package chrome
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// userDataDir returns the absolute path of the directory holding the
+// profiles of the current user.
+func (reader CookieReader) userDataDir(operatingSystem string) (string, error) {
+	path, found := reader.userDataPathMap.Get(operatingSystem)
+	if !found {
+		return "", errors.New("Unsupported operating system")
+	}
+
+	home, err := homeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, path), nil
+}
+
+// homeDir returns the home directory of the current user. Tests replace it.
+var homeDir = func() (string, error) {
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return currentUser.HomeDir, nil
+}
+
+// ListProfiles returns the profiles of the current user that have a cookie file.
+func (reader CookieReader) ListProfiles(operatingSystem string) ([]kooky.Profile, error) {
+	userDataDir, err := reader.userDataDir(operatingSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	return listProfiles(userDataDir)
+}
+
+// GetProfileCookieFilePath returns the cookie file of the profile with the given directory or display name.
+func (reader CookieReader) GetProfileCookieFilePath(operatingSystem string, profile string) (string, error) {
+	profiles, err := reader.ListProfiles(operatingSystem)
+	if err != nil {
+		return "", err
+	}
+
+	found := kooky.FindProfile(profile, profiles)
+	if found == nil {
+		return "", kooky.ErrProfileNotFound
+	}
+	return found.CookieFile, nil
+}
+
+// listProfiles returns the profiles in userDataDir. Their names and e-mail
+// addresses come from the info cache of the Local State file, if any.
+func listProfiles(userDataDir string) ([]kooky.Profile, error) {
+	var infoCache map[string]ProfileInfo
+	if localState, err := ReadLocalState(filepath.Join(userDataDir, localStateFile)); err == nil {
+		infoCache = localState.Profile.InfoCache
+	}
+
+	entries, err := ioutil.ReadDir(userDataDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []kooky.Profile
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		dir := filepath.Join(userDataDir, entry.Name())
+		file := cookieFile(dir)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		profile := kooky.Profile{
+			ID:         entry.Name(),
+			Name:       entry.Name(),
+			Dir:        dir,
+			CookieFile: file,
+		}
+		if info, ok := infoCache[entry.Name()]; ok {
+			if info.Name != "" {
+				profile.Name = info.Name
+			}
+			profile.Email = info.UserName
+		}
+		profiles = append(profiles, profile)
+	}
+
+	// Browsers like Opera keep a single profile in the user data directory.
+	if file := cookieFile(userDataDir); len(profiles) == 0 {
+		if _, err := os.Stat(file); err == nil {
+			name := filepath.Base(userDataDir)
+			profiles = append(profiles, kooky.Profile{
+				ID:         name,
+				Name:       name,
+				Dir:        userDataDir,
+				CookieFile: file,
+			})
+		}
+	}
+
+	return profiles, nil
+}
+
+// cookieFile returns the cookie file of the profile in dir. Chrome 96 moved
+// it into the Network directory.
+func cookieFile(dir string) string {
+	networkCookiePath := filepath.Join(dir, "Network", "Cookies")
+	if _, err := os.Stat(networkCookiePath); err == nil {
+		return networkCookiePath
+	}
+	return filepath.Join(dir, "Cookies")
+}
@@ -0,0 +1,584 @@
+package chrome
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kgoins/kooky/internal/sqliteutil"
+	"github.com/kgoins/kooky/internal/storefs"
+	kooky "github.com/kgoins/kooky/pkg"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// ErrLocked is returned for a cookie database whose browser is running, or
+// that another sqlite connection holds a lock on.
+var ErrLocked = errors.New("cookie database is in use")
+
+// CookieWriter inserts, updates and deletes cookies in the cookie database
+// of Chrome or another Chromium-based browser, encrypting their values the
+// way the browser does. It changes the database with sqlite, in a single
+// transaction, and fails with ErrLocked while the browser is running.
+type CookieWriter struct {
+	reader CookieReader
+}
+
+// NewCookieWriter returns a new CookieWriter for Chrome, configured with the
+// options of NewCookieReader. On Linux, values are encrypted as "v11" values
+// with the key given with WithKeyProvider, or else as "v10" values, which
+// Chrome reads whatever keyring it uses.
+func NewCookieWriter(options ...Option) CookieWriter {
+	return NewBrowserCookieWriter(chromeBrowser(), options...)
+}
+
+// NewBrowserCookieWriter returns a new CookieWriter for a browser built on
+// Chromium.
+func NewBrowserCookieWriter(browser Browser, options ...Option) CookieWriter {
+	return CookieWriter{reader: NewBrowserCookieReader(browser, options...)}
+}
+
+// WriteCookies writes cookies to the cookie database filename. A cookie
+// replaces the one it conflicts with in the database's UNIQUE index, which
+// has the same domain, name and path, and in newer schemas the same
+// partition and source; other cookies are inserted.
+func (writer CookieWriter) WriteCookies(filename string, cookies []*kooky.Cookie) error {
+	return writer.update(filename, func(table *cookieTable, encrypter *encrypter) error {
+		now := time.Now()
+		for i, cookie := range cookies {
+			// Chrome keeps creation times distinct; older schemas key the
+			// table on them.
+			now := now.Add(time.Duration(i) * time.Microsecond)
+			if err := table.put(encrypter, cookie, now); err != nil {
+				return fmt.Errorf("cookie %q of %s: %v", cookie.Name, cookie.Domain, err)
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteCookies deletes the cookies matching filters from the cookie
+// database filename and returns how many it deleted. As in ReadCookies,
+// filters are applied before values are decrypted.
+func (writer CookieWriter) DeleteCookies(filename string, filters ...kooky.Filter) (int, error) {
+	deleted := 0
+	err := writer.update(filename, func(table *cookieTable, _ *encrypter) error {
+		var rowIDs []int64
+		err := table.visit(func(rowID int64, values []interface{}) error {
+			cookie, _, err := recordCookie(table.columns, values, rowID)
+			if err != nil {
+				return fmt.Errorf("row %d: %v", rowID, err)
+			}
+			cookie.Browser = writer.reader.browser
+			cookie.Profile = profileName(storefs.OS(), filename)
+			cookie.File = filename
+
+			if kooky.FilterCookie(cookie, filters...) {
+				rowIDs = append(rowIDs, rowID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, rowID := range rowIDs {
+			if err := table.delete(rowID); err != nil {
+				return err
+			}
+		}
+		deleted = len(rowIDs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
+
+// update applies change to the cookies table of the database filename in
+// one transaction.
+func (writer CookieWriter) update(filename string, change func(*cookieTable, *encrypter) error) error {
+	if profileInUse(filename) {
+		return ErrLocked
+	}
+	// sqlite would create a database that does not exist.
+	if _, err := os.Stat(filename); err != nil {
+		return err
+	}
+
+	// The write lock is taken when the transaction begins, rather than when
+	// it first writes.
+	db, err := sql.Open("sqlite", filename+"?_txlock=immediate")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return lockError(err)
+	}
+	defer tx.Rollback()
+
+	table, err := readCookieTable(tx)
+	if err != nil {
+		return lockError(err)
+	}
+	version, err := metaVersion(tx)
+	if err != nil {
+		return err
+	}
+
+	// Linux values are only encrypted with a key that was given; the
+	// keyring's is not needed for "v10" values.
+	keys := writer.reader.keys
+	if writer.reader.operatingSystem == "windows" || writer.reader.operatingSystem == "darwin" {
+		keys = writer.reader.keyProvider(storefs.OS(), filename)
+	}
+	encrypter := &encrypter{
+		decrypter:  decrypter{operatingSystem: writer.reader.operatingSystem, keys: keys},
+		hashDomain: version >= domainHashVersion,
+	}
+
+	if err := change(table, encrypter); err != nil {
+		return lockError(err)
+	}
+	return lockError(tx.Commit())
+}
+
+// profileLockFiles are kept in the user data directory while the browser
+// runs: a symbolic link to its host and process on macOS and Linux, and a
+// file it holds open on Windows.
+var profileLockFiles = []string{"SingletonLock", "lockfile"}
+
+// profileInUse reports whether the browser runs with the user data
+// directory of the cookie database filename, the one holding its Local
+// State. A database outside of a user data directory is never in use.
+func profileInUse(filename string) bool {
+	localState, err := findLocalState(storefs.OS(), filename)
+	if err != nil {
+		return false
+	}
+	for _, name := range profileLockFiles {
+		// SingletonLock links to a file that does not exist.
+		if _, err := os.Lstat(filepath.Join(filepath.Dir(localState), name)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// lockError returns ErrLocked for the errors sqlite returns when another
+// connection holds a lock on the database, and err otherwise.
+func lockError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() & 0xff {
+		case sqlite3.SQLITE_BUSY, sqlite3.SQLITE_LOCKED:
+			return fmt.Errorf("%w: %v", ErrLocked, err)
+		}
+	}
+	return err
+}
+
+// isConstraintError reports whether err is a violated constraint.
+func isConstraintError(err error) bool {
+	var sqliteErr *sqlite.Error
+	return errors.As(err, &sqliteErr) && sqliteErr.Code()&0xff == sqlite3.SQLITE_CONSTRAINT
+}
+
+// metaVersion returns the version in the meta table, or 0 if there is none.
+func metaVersion(tx *sql.Tx) (int, error) {
+	var version string
+	err := tx.QueryRow("SELECT value FROM meta WHERE key = 'version'").Scan(&version)
+	if err != nil {
+		if err == sql.ErrNoRows || strings.Contains(err.Error(), "no such table") {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.Atoi(version)
+}
+
+// Names of the columns of the cookies table that are only written.
+var (
+	colTopFrameSiteKey      = []string{"top_frame_site_key"}
+	colLastUpdateUTC        = []string{"last_update_utc"}
+	colSourceType           = []string{"source_type"}
+	colIsSameParty          = []string{"is_same_party"}
+	colHasCrossSiteAncestor = []string{"has_cross_site_ancestor"}
+)
+
+// Chrome's values for a cookie set without a SameSite attribute, and from
+// an unknown port.
+const (
+	sameSiteUnspecifiedValue int64 = -1
+	portUnspecified          int64 = -1
+)
+
+// cookieTable is the cookies table of a database changed in the
+// transaction tx. Columns indexes the columns in the order of SELECT *.
+type cookieTable struct {
+	tx      *sql.Tx
+	columns sqliteutil.Columns
+	// stmts are the statements prepared in tx, by query.
+	stmts map[string]*sql.Stmt
+
+	// key are the columns naming a cookie, its domain, name, path and
+	// partition, and source those of the scheme and port it was set from,
+	// of which Chrome keeps one cookie each.
+	key    []string
+	source []string
+
+	// rows holds the rows by their key, in the order of their rowids.
+	// Older schemas index the table on the domain alone, which many
+	// cookies may share.
+	rows map[string][]*tableRow
+}
+
+// tableRow is a row of the cookies table as cookieTable indexes it.
+type tableRow struct {
+	rowID   int64
+	created interface{}
+	source  []interface{}
+}
+
+func readCookieTable(tx *sql.Tx) (*cookieTable, error) {
+	rows, err := tx.Query("PRAGMA table_info(cookies)")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := &cookieTable{tx: tx, columns: sqliteutil.Columns{}, stmts: map[string]*sql.Stmt{}}
+	for rows.Next() {
+		var (
+			cid        int
+			name, kind string
+			notNull    bool
+			def        interface{}
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &kind, &notNull, &def, &pk); err != nil {
+			return nil, err
+		}
+		table.columns[name] = cid
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(table.columns) == 0 {
+		return nil, errors.New("no cookies table")
+	}
+
+	for _, column := range [][]string{colHostKey, colName, colPath} {
+		if !table.columns.Has(column...) {
+			return nil, fmt.Errorf("expected column %q in cookies table", column[0])
+		}
+	}
+	for _, names := range [][]string{colHostKey, colName, colPath, colTopFrameSiteKey} {
+		if table.columns.Has(names[0]) {
+			table.key = append(table.key, names[0])
+		}
+	}
+	for _, names := range [][]string{colSourceScheme, colSourcePort} {
+		if table.columns.Has(names[0]) {
+			table.source = append(table.source, names[0])
+		}
+	}
+	if err := table.index(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// index reads the key, source and creation time of each row into rows.
+func (table *cookieTable) index() error {
+	creation := "NULL"
+	if table.columns.Has(colCreationUTC...) {
+		creation = colCreationUTC[0]
+	}
+	columns := append(append([]string{"rowid", creation}, table.key...), table.source...)
+	rows, err := table.tx.Query("SELECT " + strings.Join(columns, ", ") + " FROM cookies ORDER BY rowid")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	table.rows = map[string][]*tableRow{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		dest := make([]interface{}, len(values))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		rowID, _ := sqliteutil.ToInt64(values[0])
+		key := rowKey(values[2 : 2+len(table.key)])
+		table.rows[key] = append(table.rows[key], &tableRow{rowID: rowID, created: values[1], source: values[2+len(table.key):]})
+	}
+	return rows.Err()
+}
+
+// rowKey returns a string identifying the column values.
+func rowKey(values []interface{}) string {
+	return fmt.Sprintf("%#v", values)
+}
+
+// rowValues returns the values of row in columns.
+func rowValues(row map[string]interface{}, columns []string) []interface{} {
+	values := make([]interface{}, len(columns))
+	for i, column := range columns {
+		values[i] = row[column]
+	}
+	return values
+}
+
+// visit calls f with the rowid and values of each row.
+func (table *cookieTable) visit(f func(rowID int64, values []interface{}) error) error {
+	rows, err := table.tx.Query("SELECT rowid, * FROM cookies")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rowID int64
+		values := make([]interface{}, len(table.columns))
+		dest := []interface{}{&rowID}
+		for i := range values {
+			dest = append(dest, &values[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err := f(rowID, values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// put inserts cookie into the cookies table, or replaces the row of the
+// same cookie, keeping that row's creation time unless cookie has one.
+func (table *cookieTable) put(encrypter *encrypter, cookie *kooky.Cookie, now time.Time) error {
+	row := map[string]interface{}{}
+	set := func(names []string, value interface{}) {
+		for _, name := range names {
+			if table.columns.Has(name) {
+				row[name] = value
+				return
+			}
+		}
+	}
+
+	domain := cookie.Domain
+	if !cookie.HostOnly && !strings.HasPrefix(domain, ".") {
+		domain = "." + domain
+	}
+	path := cookie.Path
+	if path == "" {
+		path = "/"
+	}
+	encryptedValue, err := encrypter.encrypt(cookie.Value, domain)
+	if err != nil {
+		return err
+	}
+	creation := cookie.Creation
+	if creation.IsZero() {
+		creation = now
+	}
+	lastAccess := cookie.LastAccess
+	if lastAccess.IsZero() {
+		lastAccess = creation
+	}
+	// Chrome keeps the expiry of persistent cookies only.
+	expires := time.Time{}
+	if cookie.Persistent {
+		expires = cookie.Expires
+	}
+
+	set(colCreationUTC, chromeTimestamp(creation))
+	set(colHostKey, domain)
+	set(colTopFrameSiteKey, "")
+	set(colName, cookie.Name)
+	set(colValue, "")
+	set(colEncryptedValue, encryptedValue)
+	set(colPath, path)
+	set(colExpiresUTC, chromeTimestamp(expires))
+	set(colHasExpires, boolInt(!expires.IsZero()))
+	set(colIsPersistent, boolInt(cookie.Persistent))
+	set(colIsSecure, boolInt(cookie.Secure))
+	set(colIsHTTPOnly, boolInt(cookie.HttpOnly))
+	set(colLastAccessUTC, chromeTimestamp(lastAccess))
+	set(colLastUpdateUTC, chromeTimestamp(now))
+	set(colPriority, chromePriorityValue(cookie.Priority))
+	set(colSameSite, chromeSameSiteValue(cookie.SameSite))
+	set(colSourceScheme, chromeSourceSchemeValue(cookie.SourceScheme))
+	sourcePort := portUnspecified
+	if cookie.SourcePort > 0 {
+		sourcePort = int64(cookie.SourcePort)
+	}
+	set(colSourcePort, sourcePort)
+	set(colSourceType, int64(0))
+	set(colIsSameParty, int64(0))
+	set(colHasCrossSiteAncestor, int64(0))
+
+	// Newer schemas tell cookies set from other schemes and ports apart; a
+	// cookie without a source replaces the one otherwise the same.
+	key := rowKey(rowValues(row, table.key))
+	existing := table.rows[key]
+	if cookie.SourceScheme == kooky.SourceSchemeUnset && len(existing) > 0 {
+		for i, column := range table.source {
+			row[column] = existing[0].source[i]
+		}
+	}
+	keepCreation := cookie.Creation.IsZero()
+
+	source := rowKey(rowValues(row, table.source))
+	for _, r := range existing {
+		if rowKey(r.source) == source {
+			return table.replace(key, r, row, keepCreation)
+		}
+	}
+	err = table.insert(key, row)
+	if !isConstraintError(err) || len(existing) == 0 {
+		return err
+	}
+	// The UNIQUE index of some schemas leaves out the source.
+	return table.replace(key, existing[0], row, keepCreation)
+}
+
+// replace replaces the row r of key with row, keeping its creation time if
+// keepCreation is set.
+func (table *cookieTable) replace(key string, r *tableRow, row map[string]interface{}, keepCreation bool) error {
+	if keepCreation && table.columns.Has(colCreationUTC...) {
+		row[colCreationUTC[0]] = r.created
+	}
+	if err := table.delete(r.rowID); err != nil {
+		return err
+	}
+	rows := table.rows[key]
+	for i := range rows {
+		if rows[i] == r {
+			table.rows[key] = append(rows[:i:i], rows[i+1:]...)
+			break
+		}
+	}
+	return table.insert(key, row)
+}
+
+// insert inserts row, of key, into the table.
+func (table *cookieTable) insert(key string, row map[string]interface{}) error {
+	// The columns are sorted so that each row reuses the statement.
+	var columns, placeholders []string
+	for column := range row {
+		columns = append(columns, column)
+		placeholders = append(placeholders, "?")
+	}
+	sort.Strings(columns)
+
+	stmt, err := table.stmt("INSERT INTO cookies (" + strings.Join(columns, ", ") + ") VALUES (" + strings.Join(placeholders, ", ") + ")")
+	if err != nil {
+		return err
+	}
+	result, err := stmt.Exec(rowValues(row, columns)...)
+	if err != nil {
+		return err
+	}
+	rowID, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	var created interface{}
+	if table.columns.Has(colCreationUTC...) {
+		created = row[colCreationUTC[0]]
+	}
+	table.rows[key] = append(table.rows[key], &tableRow{rowID: rowID, created: created, source: rowValues(row, table.source)})
+	return nil
+}
+
+func (table *cookieTable) delete(rowID int64) error {
+	stmt, err := table.stmt("DELETE FROM cookies WHERE rowid = ?")
+	if err != nil {
+		return err
+	}
+	_, err = stmt.Exec(rowID)
+	return err
+}
+
+// stmt returns the statement of query, preparing it on first use. The
+// statements are closed with the transaction.
+func (table *cookieTable) stmt(query string) (*sql.Stmt, error) {
+	if stmt, ok := table.stmts[query]; ok {
+		return stmt, nil
+	}
+	stmt, err := table.tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	table.stmts[query] = stmt
+	return stmt, nil
+}
+
+// chromeTimestamp is the inverse of chromeCookieDate: it returns the
+// microseconds since the Windows epoch, or 0 for the zero time. Unlike
+// UnixNano, it holds dates past 2262, which cookies meant never to expire
+// are often given.
+func chromeTimestamp(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()*1e6 + int64(t.Nanosecond()/1e3) + windowsToUnixMicrosecondsOffset
+}
+
+func chromeSameSiteValue(sameSite kooky.SameSite) int64 {
+	switch sameSite {
+	case kooky.SameSiteNone:
+		return 0
+	case kooky.SameSiteLax:
+		return 1
+	case kooky.SameSiteStrict:
+		return 2
+	default:
+		return sameSiteUnspecifiedValue
+	}
+}
+
+// chromePriorityValue returns Chrome's CookiePriority, which defaults to
+// medium.
+func chromePriorityValue(priority kooky.Priority) int64 {
+	switch priority {
+	case kooky.PriorityLow:
+		return 0
+	case kooky.PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
+func chromeSourceSchemeValue(scheme kooky.SourceScheme) int64 {
+	switch scheme {
+	case kooky.SourceSchemeNonSecure:
+		return 1
+	case kooky.SourceSchemeSecure:
+		return 2
+	default:
+		return 0
+	}
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
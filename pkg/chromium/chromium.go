@@ -0,0 +1,9 @@
+package chromium
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Chromium browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("chromium")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}
@@ -0,0 +1,9 @@
+package edge
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Microsoft Edge browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("edge")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}
@@ -0,0 +1,114 @@
+package firefox
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/kgoins/kooky/internal/storefs"
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// containersFile is the name of the file in a profile directory that
+// holds the Multi-Account Containers identities.
+const containersFile = "containers.json"
+
+// containerLabels are the names of the built-in containers, which
+// containers.json refers to by localization ID.
+var containerLabels = map[string]string{
+	"userContextPersonal.label": "Personal",
+	"userContextWork.label":     "Work",
+	"userContextBanking.label":  "Banking",
+	"userContextShopping.label": "Shopping",
+}
+
+// Container is a container identity from containers.json.
+type Container struct {
+	UserContextID int    `json:"userContextId"`
+	Name          string `json:"name"`
+	L10nID        string `json:"l10nID"`
+	Icon          string `json:"icon"`
+	Color         string `json:"color"`
+	Public        bool   `json:"public"`
+}
+
+// DisplayName returns the name Firefox displays for the container.
+func (container Container) DisplayName() string {
+	if container.Name != "" {
+		return container.Name
+	}
+	return containerLabels[container.L10nID]
+}
+
+// ReadContainers reads the public container identities of the containers.json file at filename.
+func ReadContainers(filename string) ([]Container, error) {
+	return readContainers(storefs.OS(), filename)
+}
+
+func readContainers(files storefs.FS, name string) ([]Container, error) {
+	data, err := files.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Identities []Container `json:"identities"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+	for _, container := range file.Identities {
+		if container.Public {
+			containers = append(containers, container)
+		}
+	}
+	return containers, nil
+}
+
+// containerNames returns the container names by userContextId of the
+// profile in profileDir. A profile without a readable containers.json has
+// no named containers.
+func containerNames(files storefs.FS, profileDir string) map[int]string {
+	containers, err := readContainers(files, files.Join(profileDir, containersFile))
+	if err != nil {
+		return nil
+	}
+
+	names := make(map[int]string, len(containers))
+	for _, container := range containers {
+		names[container.UserContextID] = container.DisplayName()
+	}
+	return names
+}
+
+// ParseOriginAttributes parses the origin attributes suffix Firefox stores
+// in the originAttributes column, e.g. "^userContextId=2&firstPartyDomain=example.com".
+func ParseOriginAttributes(suffix string) (kooky.OriginAttributes, error) {
+	var attrs kooky.OriginAttributes
+	if suffix == "" {
+		return attrs, nil
+	}
+
+	values, err := url.ParseQuery(strings.TrimPrefix(suffix, "^"))
+	if err != nil {
+		return attrs, err
+	}
+
+	if v := values.Get("userContextId"); v != "" {
+		if attrs.UserContextID, err = strconv.Atoi(v); err != nil {
+			return attrs, err
+		}
+	}
+	if v := values.Get("privateBrowsingId"); v != "" {
+		if attrs.PrivateBrowsingID, err = strconv.Atoi(v); err != nil {
+			return attrs, err
+		}
+	}
+	attrs.FirstPartyDomain = values.Get("firstPartyDomain")
+	attrs.PartitionKey = values.Get("partitionKey")
+
+	return attrs, nil
+}
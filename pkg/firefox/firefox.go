@@ -3,13 +3,16 @@ package firefox
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
-	"os/user"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/go-sqlite/sqlite3"
+	"github.com/kgoins/kooky/internal/sqliteutil"
+	"github.com/kgoins/kooky/internal/storefs"
 	kooky "github.com/kgoins/kooky/pkg"
 )
 
@@ -18,9 +21,9 @@ var installLocationPathMap kooky.DefaultPathMap
 
 func init() {
 	cookiePathMap = kooky.NewDefaultPathMap()
-	cookiePathMap.Add("windows", `AppData\Roaming\Mozilla\Firefox\Profiles\`)
-	cookiePathMap.Add("darwin", "Library/Application Support/Firefox/Profiles/")
-	cookiePathMap.Add("linux", ".mozilla/firefox/")
+	cookiePathMap.Add("windows", `AppData\Roaming\Mozilla\Firefox`)
+	cookiePathMap.Add("darwin", "Library/Application Support/Firefox")
+	cookiePathMap.Add("linux", ".mozilla/firefox")
 
 	installLocationPathMap = kooky.NewDefaultPathMap()
 	installLocationPathMap.Add("windows", `C:\Program Files\Mozilla Firefox\firefox.exe`)
@@ -52,127 +55,278 @@ func (reader CookieReader) GetDefaultInstallPath(operatingSystem string) (string
 	return path, nil
 }
 
-func getDefaultProfile(profileDirPath string) (string, error) {
-	profileDir, err := ioutil.ReadDir(profileDirPath)
+// GetDefaultCookieFilePath returns the absolute filepath for the file used to store cookies on the current OS.
+func (reader CookieReader) GetDefaultCookieFilePath(operatingSystem string) (string, error) {
+	firefoxDir, err := reader.firefoxDir(operatingSystem)
 	if err != nil {
 		return "", err
 	}
 
-	for _, entry := range profileDir {
-		if entry.IsDir() {
-			if strings.Contains(entry.Name(), ".default") {
-				return entry.Name(), nil
-			}
-		}
+	profileDir, err := defaultProfileDir(firefoxDir)
+	if err != nil {
+		return "", err
 	}
 
-	return "", errors.New("Unable to locate default profile")
+	return filepath.Join(profileDir, cookieFileName), nil
 }
 
-// GetDefaultCookieFilePath returns the absolute filepath for the file used to store cookies on the current OS.
-func (reader CookieReader) GetDefaultCookieFilePath(operatingSystem string) (string, error) {
-	path, found := reader.cookiePathMap.Get(operatingSystem)
-	if !found {
-		return "", errors.New("Unsupported operating system")
-	}
+// ReadAllCookies reads all cookies from the input firefox sqlite database filepath.
+func (reader CookieReader) ReadAllCookies(filename string) ([]*kooky.Cookie, error) {
+	return reader.ReadCookies(filename)
+}
 
-	currentUser, err := user.Current()
-	if err != nil {
-		return "", err
-	}
+// ReadCookies reads cookies from the input firefox sqlite database filepath, filtered by the input filters.
+// Session cookies are read from the session store of the same profile, if any.
+func (reader CookieReader) ReadCookies(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.OS(), filename, true, filters)
+	return cookies, err
+}
 
-	profileDirPath := filepath.Join(currentUser.HomeDir, path)
-	defaultProfile, err := getDefaultProfile(profileDirPath)
-	if err != nil {
-		return "", err
-	}
+// ReadCookiesLenient reads cookies like ReadCookies, but skips the cookies it fails to read,
+// returning an error for each of them.
+func (reader CookieReader) ReadCookiesLenient(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	return reader.readCookies(storefs.OS(), filename, false, filters)
+}
 
-	return filepath.Join(profileDirPath, defaultProfile, "cookies.sqlite"), nil
+// ReadCookiesFS reads cookies like ReadCookies from the file name in fsys, e.g. "cookies.sqlite" in a
+// profile directory. Its write-ahead log, containers and session store are looked up in fsys.
+func (reader CookieReader) ReadCookiesFS(fsys fs.FS, name string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.FromFS(fsys), name, true, filters)
+	return cookies, err
 }
 
-// ReadCookies reads cookies from the input firefox sqlite database filepath, filtered by the input parameters.
-func (reader CookieReader) ReadCookies(filename string, domainFilter string, nameFilter string, expireAfter time.Time) ([]*kooky.Cookie, error) {
-	return reader.ReadAllCookies(filename)
+// ReadCookiesFrom reads cookies like ReadCookies from the size bytes of a cookies.sqlite database in r.
+// Without the rest of the profile, session cookies and container names are not read.
+func (reader CookieReader) ReadCookiesFrom(r io.ReaderAt, size int64, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return nil, err
+	}
+
+	cookies, _, err := reader.readCookies(storefs.Memory(data), "", true, filters)
+	return cookies, err
 }
 
-// ReadAllCookies reads all cookies from the input firefox sqlite database filepath.
-func (reader CookieReader) ReadAllCookies(filename string) ([]*kooky.Cookie, error) {
+// readCookies reads the cookies of filename in files. In strict mode the first cookie that fails aborts the read.
+func (reader CookieReader) readCookies(files storefs.FS, filename string, strict bool, filters []kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
 	var cookies []*kooky.Cookie
-	db, err := sqlite3.Open(filename)
+	var cookieErrors []kooky.CookieError
+
+	// Read a snapshot including the write-ahead log, which holds the
+	// cookies set since the browser last checkpointed the database.
+	f, err := sqliteutil.ReadSnapshot(files, filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	defer db.Close()
 
-	err = db.VisitTableRecords("moz_cookies", func(rowId *int64, rec sqlite3.Record) error {
-		if lRec := len(rec.Values); lRec != 13 && lRec != 14 {
-			return fmt.Errorf("got %d columns, but expected 13 or 14", lRec)
+	// Firefox has added and dropped columns of moz_cookies over time, so
+	// columns are looked up by name rather than by position.
+	columns, err := sqliteutil.ReadColumns(f, "moz_cookies")
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, column := range [][]string{colHost, colName, colPath} {
+		if !columns.Has(column...) {
+			return nil, nil, fmt.Errorf("expected column %q in moz_cookies table", column[0])
 		}
+	}
+
+	db, err := sqlite3.OpenFrom(f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer db.Close()
+
+	containers := containerNames(files, files.Dir(filename))
+	persisted := make(map[cookieKey]bool)
 
+	err = sqliteutil.VisitTableRecords(db, "moz_cookies", func(rowId *int64, rec sqlite3.Record) error {
 		cookie := kooky.Cookie{}
 		var ok bool
 
-		// Name
-		cookie.Name, ok = rec.Values[3].(string)
-		if !ok {
-			return fmt.Errorf("got unexpected value for Name %v", rec.Values[3])
+		var id int64 = -1
+		if rowId != nil {
+			id = *rowId
+		}
+		fail := func(stage kooky.ErrorStage, err error) error {
+			cookieErr := kooky.CookieError{RowID: id, Domain: cookie.Domain, Name: cookie.Name, Stage: stage, Err: err}
+			if strict {
+				return cookieErr
+			}
+			cookieErrors = append(cookieErrors, cookieErr)
+			return nil
 		}
 
-		// Value
-		cookie.Value, ok = rec.Values[4].(string)
+		// Domain
+		cookie.Domain, ok = columns.Value(rec.Values, colHost...).(string)
 		if !ok {
-			return fmt.Errorf("got unexpected value for Value %v", rec.Values[4])
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Domain %v", columns.Value(rec.Values, colHost...)))
 		}
 
-		// Domain
-		cookie.Domain, ok = rec.Values[1].(string)
+		// Name
+		cookie.Name, ok = columns.Value(rec.Values, colName...).(string)
 		if !ok {
-			return fmt.Errorf("got unexpected value for Domain %v", rec.Values[1])
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Name %v", columns.Value(rec.Values, colName...)))
+		}
+
+		// Value
+		cookie.Value, ok = columns.Value(rec.Values, colValue...).(string)
+		if !ok && columns.Value(rec.Values, colValue...) != nil {
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Value %v", columns.Value(rec.Values, colValue...)))
 		}
 
 		// Path
-		cookie.Path, ok = rec.Values[6].(string)
+		cookie.Path, ok = columns.Value(rec.Values, colPath...).(string)
 		if !ok {
-			return fmt.Errorf("got unexpected value for Path %v", rec.Values[6])
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Path %v", columns.Value(rec.Values, colPath...)))
 		}
 
 		// Expires
-		if int32Value, ok := rec.Values[7].(int32); ok {
-			cookie.Expires = time.Unix(int64(int32Value), 0)
-		} else if uint64Value, ok := rec.Values[7].(uint64); ok {
-			cookie.Expires = time.Unix(int64(uint64Value), 0)
-		} else {
-			return fmt.Errorf("got unexpected value for Expires %v (type %T)", rec.Values[7], rec.Values[7])
+		expiry, ok := sqliteutil.ToInt64(columns.Value(rec.Values, colExpiry...))
+		if !ok {
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Expires %v (type %T)", columns.Value(rec.Values, colExpiry...), columns.Value(rec.Values, colExpiry...)))
 		}
+		cookie.Expires = firefoxExpiry(expiry)
 
 		// Creation
-		int64Value, ok := rec.Values[9].(int64)
+		creationTime, ok := sqliteutil.ToInt64(columns.Value(rec.Values, colCreationTime...))
 		if !ok {
-			return fmt.Errorf("got unexpected value for Creation %v (type %T)", rec.Values[9], rec.Values[9])
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for Creation %v (type %T)", columns.Value(rec.Values, colCreationTime...), columns.Value(rec.Values, colCreationTime...)))
 		}
-		cookie.Creation = time.Unix(int64Value/1e6, 0) // drop nanoseconds
+		cookie.Creation = time.Unix(creationTime/1e6, 0) // drop nanoseconds
 
-		// Secure
-		intValue, ok := rec.Values[10].(int)
-		if !ok {
-			return fmt.Errorf("got unexpected value for Secure %v", rec.Values[10])
+		// LastAccess
+		if lastAccessed := sqliteutil.ColumnInt(columns, rec.Values, 0, colLastAccessed...); lastAccessed != 0 {
+			cookie.LastAccess = time.Unix(lastAccessed/1e6, 0) // drop nanoseconds
 		}
-		cookie.Secure = intValue > 0
 
-		// HttpOnly
-		intValue, ok = rec.Values[11].(int)
-		if !ok {
-			return fmt.Errorf("got unexpected value for HttpOnly %v", rec.Values[11])
+		cookie.Secure = sqliteutil.ColumnInt(columns, rec.Values, 0, colIsSecure...) > 0
+		cookie.HttpOnly = sqliteutil.ColumnInt(columns, rec.Values, 0, colIsHTTPOnly...) > 0
+		if columns.Has(colSameSite...) {
+			cookie.SameSite = firefoxSameSite(columns.Value(rec.Values, colSameSite...))
+		}
+		cookie.SourceScheme = firefoxSchemeMap(sqliteutil.ColumnInt(columns, rec.Values, 0, colSchemeMap...))
+
+		// OriginAttributes
+		suffix, ok := columns.Value(rec.Values, colOriginAttributes...).(string)
+		if !ok && columns.Value(rec.Values, colOriginAttributes...) != nil {
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for OriginAttributes %v", columns.Value(rec.Values, colOriginAttributes...)))
+		}
+		originAttributes, err := ParseOriginAttributes(suffix)
+		if err != nil {
+			return fail(kooky.StageConvert, fmt.Errorf("got unexpected value for OriginAttributes %q: %v", suffix, err))
+		}
+		cookie.OriginAttributes = originAttributes
+		cookie.Container = containers[cookie.OriginAttributes.UserContextID]
+
+		cookie.HostOnly = !strings.HasPrefix(cookie.Domain, ".")
+		cookie.Persistent = true // session cookies are not written to cookies.sqlite
+
+		cookie.Browser = "firefox"
+		cookie.Profile = profileOf(files, filename)
+		cookie.File = filename
+		persisted[keyOf(&cookie)] = true
+
+		if !kooky.FilterCookie(&cookie, filters...) {
+			return nil
 		}
-		cookie.HttpOnly = intValue > 0
 
 		cookies = append(cookies, &cookie)
 
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		if strict {
+			return nil, nil, err
+		}
+		cookieErrors = append(cookieErrors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: err})
 	}
 
-	return cookies, nil
+	// The session store is read on a best-effort basis, even in strict
+	// mode: its failures are recorded, and cost no cookie of cookies.sqlite.
+	if sessionStore := findSessionStore(files, filename); sessionStore != "" {
+		sessionCookies, sessionErrors, err := readSessionStore(files, sessionStore, containers, false, filters)
+		if err != nil {
+			sessionErrors = append(sessionErrors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: err})
+		}
+		cookies = mergeCookies(cookies, sessionCookies, persisted)
+		cookieErrors = append(cookieErrors, sessionErrors...)
+	}
+
+	return cookies, cookieErrors, nil
+}
+
+// profileOf returns the name of the profile directory holding filename. A
+// cookie file read from memory has no profile.
+func profileOf(files storefs.FS, filename string) string {
+	if filename == "" {
+		return ""
+	}
+	return files.Base(files.Dir(filename))
+}
+
+// Names of the columns of the moz_cookies table.
+var (
+	colOriginAttributes = []string{"originAttributes"}
+	colName             = []string{"name"}
+	colValue            = []string{"value"}
+	colHost             = []string{"host"}
+	colPath             = []string{"path"}
+	colExpiry           = []string{"expiry"}
+	colLastAccessed     = []string{"lastAccessed"}
+	colCreationTime     = []string{"creationTime"}
+	colIsSecure         = []string{"isSecure"}
+	colIsHTTPOnly       = []string{"isHttpOnly"}
+	colSameSite         = []string{"sameSite"}
+	colSchemeMap        = []string{"schemeMap"}
+)
+
+// expiryMillisecondsThreshold separates expiry times in seconds, as older
+// Firefox versions store them, from those in milliseconds: as seconds it
+// is in the year 33658, as milliseconds in 2001.
+const expiryMillisecondsThreshold = 1e12
+
+// firefoxExpiry converts the expiry column, in seconds or milliseconds
+// since the Unix epoch depending on the Firefox version. An expiry of 0 is
+// no expiry, the zero time.
+func firefoxExpiry(expiry int64) time.Time {
+	if expiry == 0 {
+		return time.Time{}
+	}
+	if expiry >= expiryMillisecondsThreshold {
+		return time.Unix(expiry/1e3, (expiry%1e3)*1e6)
+	}
+	return time.Unix(expiry, 0)
+}
+
+// firefoxSchemeMap converts the schemeMap bit set of the schemes that set
+// the cookie, 1 for http and 2 for https.
+func firefoxSchemeMap(schemeMap int64) kooky.SourceScheme {
+	switch schemeMap & 3 {
+	case 1:
+		return kooky.SourceSchemeNonSecure
+	case 2:
+		return kooky.SourceSchemeSecure
+	default:
+		return kooky.SourceSchemeUnset
+	}
+}
+
+// firefoxSameSite converts the nsICookie SAMESITE_* constants.
+func firefoxSameSite(value interface{}) kooky.SameSite {
+	intValue, ok := sqliteutil.ToInt64(value)
+	if !ok {
+		return kooky.SameSiteUnspecified
+	}
+
+	switch intValue {
+	case 0:
+		return kooky.SameSiteNone
+	case 1:
+		return kooky.SameSiteLax
+	case 2:
+		return kooky.SameSiteStrict
+	default:
+		return kooky.SameSiteUnspecified
+	}
 }
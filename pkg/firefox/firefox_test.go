@@ -1,12 +1,19 @@
 package firefox
 
 import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/kgoins/kooky/internal/storefs"
 	"github.com/kgoins/kooky/internal/testutils"
+	kooky "github.com/kgoins/kooky/pkg"
 )
 
 func TestReadFirefoxCookies(t *testing.T) {
@@ -60,6 +67,106 @@ func TestReadFirefoxCookies(t *testing.T) {
 	if c.Value != "a748915ba19c6d0b" {
 		t.Errorf("c.Value=%q", c.Value)
 	}
+	if !c.LastAccess.Equal(time.Date(2018, 01, 17, 18, 24, 47, 0, tz)) {
+		t.Errorf("c.LastAccess=%q", c.LastAccess)
+	}
+	if !c.HostOnly {
+		t.Error("c.HostOnly expected true")
+	}
+	if c.Browser != "firefox" || c.File != testCookiesPath {
+		t.Errorf("c.Browser=%q, c.File=%q", c.Browser, c.File)
+	}
+}
+
+func TestReadFirefoxCookieSchemas(t *testing.T) {
+	tz := time.UTC
+	fixtures := []struct {
+		file          string
+		containerless bool // predates originAttributes
+		sameSite      bool
+	}{
+		{"firefox-cookies-v7.sqlite", true, false},  // appId and inBrowserElement
+		{"firefox-cookies-v9.sqlite", false, false}, // originAttributes
+		{"firefox-cookies-v12.sqlite", false, true}, // sameSite and schemeMap, no baseDomain
+		{"firefox-cookies-v16.sqlite", false, true}, // isPartitionedAttributeSet, expiry in milliseconds
+	}
+
+	for _, fixture := range fixtures {
+		testCookiesPath, err := testutils.GetTestDataFilePath(fixture.file)
+		if err != nil {
+			t.Fatalf("Failed to load test data file")
+		}
+
+		cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+		if err != nil {
+			t.Errorf("%s: %v", fixture.file, err)
+			continue
+		}
+		if len(cookies) != 2 {
+			t.Errorf("%s: got %d cookies, but expected 2", fixture.file, len(cookies))
+			continue
+		}
+
+		session := kooky.FindCookie(".example.com", "session", cookies)
+		if session == nil {
+			t.Errorf("%s: found no session cookie", fixture.file)
+			continue
+		}
+		if session.Value != "abc123" || session.Path != "/" || !session.Secure || !session.HttpOnly || session.HostOnly {
+			t.Errorf("%s: unexpected session cookie %+v", fixture.file, session)
+		}
+		if want := time.Date(2038, 01, 19, 3, 14, 07, 0, tz); !session.Expires.Equal(want) {
+			t.Errorf("%s: want session.Expires=%v; got %v", fixture.file, want, session.Expires)
+		}
+		if want := time.Date(2020, 06, 01, 12, 0, 0, 0, tz); !session.Creation.Equal(want) {
+			t.Errorf("%s: want session.Creation=%v; got %v", fixture.file, want, session.Creation)
+		}
+		if want := time.Date(2020, 06, 02, 8, 0, 0, 0, tz); !session.LastAccess.Equal(want) {
+			t.Errorf("%s: want session.LastAccess=%v; got %v", fixture.file, want, session.LastAccess)
+		}
+		if fixture.sameSite && (session.SameSite != kooky.SameSiteLax || session.SourceScheme != kooky.SourceSchemeSecure) {
+			t.Errorf("%s: unexpected session attributes %+v", fixture.file, session)
+		}
+
+		prefs := kooky.FindCookie("www.example.com", "prefs", cookies)
+		if prefs == nil {
+			t.Errorf("%s: found no prefs cookie", fixture.file)
+			continue
+		}
+		if prefs.Value != "dark" || prefs.Path != "/settings" || !prefs.HostOnly || prefs.Secure || prefs.HttpOnly {
+			t.Errorf("%s: unexpected prefs cookie %+v", fixture.file, prefs)
+		}
+		if want := time.Date(2030, 01, 01, 0, 0, 0, 0, tz); !prefs.Expires.Equal(want) {
+			t.Errorf("%s: want prefs.Expires=%v; got %v", fixture.file, want, prefs.Expires)
+		}
+		if want := 2; !fixture.containerless && prefs.OriginAttributes.UserContextID != want {
+			t.Errorf("%s: want prefs.OriginAttributes.UserContextID=%d; got %d", fixture.file, want, prefs.OriginAttributes.UserContextID)
+		}
+	}
+}
+
+func TestReadFirefoxCookiesWAL(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("firefox-wal/cookies.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The log holds one committed transaction, updating session and adding
+	// fresh, and frames of an uncommitted one.
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+	if c := kooky.FindCookie(".example.com", "session", cookies); c == nil || c.Value != "rotated" {
+		t.Errorf("want session cookie with value %q; got %+v", "rotated", c)
+	}
+	if c := kooky.FindCookie(".example.com", "fresh", cookies); c == nil || c.Value != "new-value" {
+		t.Errorf("want fresh cookie with value %q; got %+v", "new-value", c)
+	}
 }
 
 func TestFirefoxGetDefaultInstallPath(t *testing.T) {
@@ -89,3 +196,368 @@ func TestFirefoxGetDefaultCookiePath(t *testing.T) {
 		t.Fatalf("Unable to locate firefox cookies database")
 	}
 }
+
+func TestListFirefoxProfiles(t *testing.T) {
+	firefoxDir, err := testutils.GetTestDataFilePath("firefox-profiles")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	profiles, err := listProfiles(firefoxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(profiles) != 3 {
+		t.Fatalf("got %d profiles, but expected 3", len(profiles))
+	}
+
+	p := profiles[0]
+	if p.ID != "a9s8d7f6.work" || p.Name != "work" {
+		t.Errorf("p.ID=%q, p.Name=%q", p.ID, p.Name)
+	}
+	if want := filepath.Join(firefoxDir, "a9s8d7f6.work", "cookies.sqlite"); p.CookieFile != want {
+		t.Errorf("p.CookieFile=%q", p.CookieFile)
+	}
+}
+
+func TestFirefoxDefaultProfileDir(t *testing.T) {
+	firefoxDir, err := testutils.GetTestDataFilePath("firefox-profiles")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	// The locked install default wins over both the Default=1 profile and
+	// the first directory containing ".default".
+	profileDir, err := defaultProfileDir(firefoxDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(firefoxDir, "q1w2e3r4.default-release"); profileDir != want {
+		t.Errorf("profileDir=%q, want %q", profileDir, want)
+	}
+}
+
+func TestParseProfilesINI(t *testing.T) {
+	profilesINI, err := ParseProfilesINI(strings.NewReader(`[Profile1]
+Name=custom
+IsRelative=0
+Path=/data/firefox/custom
+
+[Profile0]
+Name=default
+IsRelative=1
+Path=Profiles/abcd.default
+Default=1
+
+[General]
+StartWithLastProfile=1
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profilesINI.Dir = "/home/user/.mozilla/firefox"
+
+	if len(profilesINI.Profiles) != 2 {
+		t.Fatalf("got %d profiles, but expected 2", len(profilesINI.Profiles))
+	}
+	if dir := profilesINI.ProfileDir(profilesINI.Profiles[0]); dir != filepath.FromSlash("/data/firefox/custom") {
+		t.Errorf("absolute profile dir=%q", dir)
+	}
+
+	profile, err := profilesINI.DefaultProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if profile.Name != "default" {
+		t.Errorf("default profile=%q", profile.Name)
+	}
+	if dir, want := profilesINI.ProfileDir(profile), filepath.Join("/home/user/.mozilla/firefox", "Profiles", "abcd.default"); dir != want {
+		t.Errorf("relative profile dir=%q, want %q", dir, want)
+	}
+
+	// installs.ini adds the default of an installation missing from profiles.ini.
+	if err := profilesINI.parseInstallsINI(strings.NewReader("[308046B0AF4A39CB]\nDefault=/data/firefox/custom\nLocked=1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if profile, err := profilesINI.DefaultProfile(); err != nil || profile.Name != "custom" {
+		t.Errorf("default profile=%q (%v), want locked install default %q", profile.Name, err, "custom")
+	}
+}
+
+func TestReadFirefoxContainerCookies(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("firefox-containers/cookies.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader()
+
+	cookies, err := reader.ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 5 {
+		t.Fatalf("got %d cookies, but expected 5", len(cookies))
+	}
+
+	want := map[string]struct {
+		container string
+		attrs     kooky.OriginAttributes
+	}{
+		"default":     {"", kooky.OriginAttributes{}},
+		"work":        {"Work", kooky.OriginAttributes{UserContextID: 2}},
+		"shopping":    {"Shopping Spree", kooky.OriginAttributes{UserContextID: 6}},
+		"private":     {"", kooky.OriginAttributes{PrivateBrowsingID: 1}},
+		"partitioned": {"", kooky.OriginAttributes{FirstPartyDomain: "example.org", PartitionKey: "(https,example.org)"}},
+	}
+	for _, c := range cookies {
+		w, ok := want[c.Value]
+		if !ok {
+			t.Errorf("unexpected cookie value %q", c.Value)
+			continue
+		}
+		if c.Container != w.container || c.OriginAttributes != w.attrs {
+			t.Errorf("cookie %q: got container %q, attributes %+v; want %q, %+v", c.Value, c.Container, c.OriginAttributes, w.container, w.attrs)
+		}
+	}
+
+	cookies, err = reader.ReadCookies(testCookiesPath, kooky.Container("Work"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Value != "work" {
+		t.Errorf("want only the Work container cookie; got %d cookies", len(cookies))
+	}
+}
+
+func TestDecodeMozLz4(t *testing.T) {
+	// "abc" followed by an overlapping match of 9 bytes at offset 3, then
+	// the literal "!".
+	data := append([]byte("mozLz40\x00\x0d\x00\x00\x00"), 0x35, 'a', 'b', 'c', 0x03, 0x00, 0x10, '!')
+	decoded, err := DecodeMozLz4(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "abcabcabcabc!"; string(decoded) != want {
+		t.Errorf("decoded %q, want %q", decoded, want)
+	}
+
+	if _, err := DecodeMozLz4([]byte("mozLz40\x00\x0d\x00\x00\x00\x35abc\x09\x00")); err == nil {
+		t.Error("want error for a match offset before the start of the output")
+	}
+	if _, err := DecodeMozLz4([]byte("mozLz40\x00\xff\xff\xff\xff\x10a")); err == nil {
+		t.Error("want error for a decompressed size beyond what the block can hold")
+	}
+}
+
+func TestReadFirefoxSessionCookies(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("firefox-session/cookies.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader()
+
+	cookies, err := reader.ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The session store repeats the GODOC_ORG_SESSION_ID cookie of cookies.sqlite.
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+	if c := kooky.FindCookie("godoc.org", "GODOC_ORG_SESSION_ID", cookies); c == nil || FromSessionStore(c) {
+		t.Errorf("want GODOC_ORG_SESSION_ID from cookies.sqlite; got %+v", c)
+	}
+
+	c := kooky.FindCookie(".sso.example.com", "SSO_SESSION", cookies)
+	if c == nil {
+		t.Fatal("SSO_SESSION cookie not found")
+	}
+	if !FromSessionStore(c) || c.Persistent {
+		t.Errorf("want non-persistent session store cookie; got file %q, persistent %v", c.File, c.Persistent)
+	}
+	if c.Value != "0123456789abcdef0123456789abcdef" || !c.Secure || !c.HttpOnly || c.SameSite != kooky.SameSiteLax {
+		t.Errorf("c=%+v", c)
+	}
+	if c.Container != "Work" || c.Profile != "firefox-session" {
+		t.Errorf("c.Container=%q, c.Profile=%q", c.Container, c.Profile)
+	}
+
+	if c := kooky.FindCookie("legacy.example.com", "legacy", cookies); c == nil || c.Value != "legacy-value" {
+		t.Errorf("want legacy per-window session cookie; got %+v", c)
+	}
+
+	sessionCookies, err := reader.ReadCookies(testCookiesPath, FromSessionStore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessionCookies) != 2 {
+		t.Errorf("got %d session store cookies, but expected 2", len(sessionCookies))
+	}
+
+	// A renamed session store is still recognized as one.
+	data, err := ioutil.ReadFile(filepath.Join(filepath.Dir(testCookiesPath), "sessionstore-backups", "recovery.jsonlz4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "kooky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	renamed := filepath.Join(dir, "recovery.bak")
+	if err := ioutil.WriteFile(renamed, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	sessionCookies, err = reader.ReadSessionStoreCookies(renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Unmerged, it holds the GODOC_ORG_SESSION_ID cookie as well.
+	if len(sessionCookies) != 3 || len(kooky.FilterCookies(sessionCookies, FromSessionStore)) != 3 {
+		t.Errorf("want 3 session store cookies from %s; got %d", renamed, len(sessionCookies))
+	}
+}
+
+func TestReadFirefoxCookiesBrokenSessionStore(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("firefox-session/cookies.sqlite")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	data, err := ioutil.ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "kooky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "cookies.sqlite")
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "sessionstore-backups"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "sessionstore-backups", "recovery.jsonlz4"), []byte("mozLz40\x00broken"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cookies of cookies.sqlite are kept, in strict mode too.
+	reader := NewCookieReader()
+	cookies, err := reader.ReadAllCookies(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := kooky.FindCookie("godoc.org", "GODOC_ORG_SESSION_ID", cookies); c == nil {
+		t.Errorf("want GODOC_ORG_SESSION_ID from cookies.sqlite; got %v", cookies)
+	}
+
+	lenient, cookieErrors, err := reader.ReadCookiesLenient(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lenient) != len(cookies) || len(cookieErrors) != 1 || cookieErrors[0].RowID != -1 {
+		t.Errorf("want the cookies of cookies.sqlite and 1 session store error; got %v, %v", lenient, cookieErrors)
+	}
+}
+
+func TestReadFirefoxSessionStoreNamelessCookie(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kooky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "sessionstore.js")
+	store := `{"cookies":[{"host":"example.com","name":"","value":"flag","path":"/"},{"host":"","name":"orphan","value":"x","path":"/"}]}`
+	if err := ioutil.WriteFile(filename, []byte(store), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, cookieErrors, err := readSessionStore(storefs.OS(), filename, nil, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Name != "" || cookies[0].Value != "flag" {
+		t.Errorf("want the nameless cookie; got %v", cookies)
+	}
+	if len(cookieErrors) != 1 || cookieErrors[0].Name != "orphan" {
+		t.Errorf("want an error for the cookie without a host; got %v", cookieErrors)
+	}
+}
+
+func TestReadFirefoxCookiesFS(t *testing.T) {
+	profileDir, err := testutils.GetTestDataFilePath("firefox-session")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	// Archive the profile, as it would be received.
+	var archive bytes.Buffer
+	w := zip.NewWriter(&archive)
+	for _, name := range []string{"cookies.sqlite", "containers.json", "sessionstore-backups/recovery.jsonlz4"} {
+		data, err := ioutil.ReadFile(filepath.Join(profileDir, filepath.FromSlash(name)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		f, err := w.Create("profile/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys, err := zip.NewReader(bytes.NewReader(archive.Bytes()), int64(archive.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := NewCookieReader()
+	cookies, err := reader.ReadCookiesFS(fsys, "profile/cookies.sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 3 {
+		t.Fatalf("got %d cookies, but expected 3", len(cookies))
+	}
+	c := kooky.FindCookie(".sso.example.com", "SSO_SESSION", cookies)
+	if c == nil || !FromSessionStore(c) || c.Container != "Work" || c.Profile != "profile" {
+		t.Errorf("want Work session store cookie of the archived profile; got %+v", c)
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(profileDir, "cookies.sqlite"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookies, err = reader.ReadCookiesFrom(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) == 0 || kooky.FindCookie(".sso.example.com", "SSO_SESSION", cookies) != nil {
+		t.Errorf("want the cookies of cookies.sqlite only; got %d cookies", len(cookies))
+	}
+}
+
+func TestFirefoxExpiry(t *testing.T) {
+	tests := []struct {
+		expiry int64
+		want   time.Time
+	}{
+		{0, time.Time{}},
+		{1893456000, time.Unix(1893456000, 0)},
+		{1893456000500, time.Unix(1893456000, 500e6)},
+	}
+	for _, test := range tests {
+		if got := firefoxExpiry(test.expiry); !got.Equal(test.want) || got.IsZero() != test.want.IsZero() {
+			t.Errorf("expiry %d: want %v; got %v", test.expiry, test.want, got)
+		}
+	}
+}
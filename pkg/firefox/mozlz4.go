@@ -0,0 +1,113 @@
+package firefox
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// mozLz4 is Firefox's framing of a single LZ4 block: a magic number and the
+// decompressed size, followed by the block.
+// https://searchfox.org/mozilla-central/source/toolkit/components/lz4/lz4.js
+
+const (
+	mozLz4Magic      = "mozLz40\x00"
+	mozLz4HeaderSize = len(mozLz4Magic) + 4
+	lz4MinMatch      = 4
+
+	// lz4MaxRatio bounds the decompressed size of an LZ4 block: each
+	// length byte adds at most 255 bytes to it.
+	lz4MaxRatio = 255
+)
+
+// DecodeMozLz4 decompresses data in Firefox's mozLz4 format, as used by the
+// .jsonlz4 session store files.
+func DecodeMozLz4(data []byte) ([]byte, error) {
+	if len(data) < mozLz4HeaderSize || string(data[:len(mozLz4Magic)]) != mozLz4Magic {
+		return nil, errors.New("not a mozLz4 file")
+	}
+
+	size := binary.LittleEndian.Uint32(data[len(mozLz4Magic):mozLz4HeaderSize])
+	decoded, err := decodeLz4Block(data[mozLz4HeaderSize:], int(size))
+	if err != nil {
+		return nil, fmt.Errorf("mozLz4: %v", err)
+	}
+	if len(decoded) != int(size) {
+		return nil, fmt.Errorf("mozLz4: decompressed %d bytes, but expected %d", len(decoded), size)
+	}
+	return decoded, nil
+}
+
+// decodeLz4Block decompresses an LZ4 block of at most size bytes.
+// https://github.com/lz4/lz4/blob/dev/doc/lz4_Block_format.md
+func decodeLz4Block(src []byte, size int) ([]byte, error) {
+	// Don't allocate what a crafted header claims before checking it.
+	if size > len(src)*lz4MaxRatio {
+		return nil, fmt.Errorf("decompressed size %d too large for %d bytes", size, len(src))
+	}
+	dst := make([]byte, 0, size)
+
+	for i := 0; i < len(src); {
+		token := src[i]
+		i++
+
+		literals, n, err := lz4Length(src[i:], int(token>>4))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		if literals > len(src)-i || literals > size-len(dst) {
+			return nil, errors.New("literals out of range")
+		}
+		dst = append(dst, src[i:i+literals]...)
+		i += literals
+
+		// The last sequence has no match.
+		if i == len(src) {
+			break
+		}
+
+		if i+2 > len(src) {
+			return nil, errors.New("truncated match offset")
+		}
+		offset := int(binary.LittleEndian.Uint16(src[i:]))
+		i += 2
+		if offset == 0 || offset > len(dst) {
+			return nil, fmt.Errorf("invalid match offset %d", offset)
+		}
+
+		matchLength, n, err := lz4Length(src[i:], int(token&0x0f))
+		if err != nil {
+			return nil, err
+		}
+		i += n
+		matchLength += lz4MinMatch
+		if matchLength > size-len(dst) {
+			return nil, errors.New("match out of range")
+		}
+
+		// Matches may overlap the bytes they produce, so copy byte by byte.
+		start := len(dst) - offset
+		for j := 0; j < matchLength; j++ {
+			dst = append(dst, dst[start+j])
+		}
+	}
+
+	return dst, nil
+}
+
+// lz4Length returns a literal or match length whose 4 bit token part is
+// length, reading its continuation bytes from src.
+func lz4Length(src []byte, length int) (int, int, error) {
+	if length != 0x0f {
+		return length, 0, nil
+	}
+
+	for n := 0; n < len(src); n++ {
+		length += int(src[n])
+		if src[n] != 0xff {
+			return length, n + 1, nil
+		}
+	}
+	return 0, 0, errors.New("truncated length")
+}
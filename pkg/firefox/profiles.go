@@ -0,0 +1,163 @@
+package firefox
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+const cookieFileName = "cookies.sqlite"
+
+// firefoxDir returns the absolute path of the directory holding
+// profiles.ini for the current user.
+func (reader CookieReader) firefoxDir(operatingSystem string) (string, error) {
+	path, found := reader.cookiePathMap.Get(operatingSystem)
+	if !found {
+		return "", errors.New("Unsupported operating system")
+	}
+
+	currentUser, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(currentUser.HomeDir, path), nil
+}
+
+// ListProfiles returns the profiles of the current user that have a cookie file.
+func (reader CookieReader) ListProfiles(operatingSystem string) ([]kooky.Profile, error) {
+	firefoxDir, err := reader.firefoxDir(operatingSystem)
+	if err != nil {
+		return nil, err
+	}
+
+	return listProfiles(firefoxDir)
+}
+
+// GetProfileCookieFilePath returns the cookie file of the profile with the given directory or display name.
+func (reader CookieReader) GetProfileCookieFilePath(operatingSystem string, profile string) (string, error) {
+	profiles, err := reader.ListProfiles(operatingSystem)
+	if err != nil {
+		return "", err
+	}
+
+	found := kooky.FindProfile(profile, profiles)
+	if found == nil {
+		return "", kooky.ErrProfileNotFound
+	}
+	return found.CookieFile, nil
+}
+
+// listProfiles returns the profiles listed in the profiles.ini of
+// firefoxDir, or else the profile directories found in it.
+func listProfiles(firefoxDir string) ([]kooky.Profile, error) {
+	profilesINI, err := ReadProfilesINI(firefoxDir)
+	if os.IsNotExist(err) {
+		return scanProfiles(firefoxDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []kooky.Profile
+	for _, section := range profilesINI.Profiles {
+		dir := profilesINI.ProfileDir(section)
+		file := filepath.Join(dir, cookieFileName)
+		if _, err := os.Stat(file); err != nil {
+			continue
+		}
+
+		name := section.Name
+		if name == "" {
+			name = profileName(filepath.Base(dir))
+		}
+		profiles = append(profiles, kooky.Profile{
+			ID:         filepath.Base(dir),
+			Name:       name,
+			Dir:        dir,
+			CookieFile: file,
+		})
+	}
+
+	return profiles, nil
+}
+
+// defaultProfileDir returns the directory of the default profile named by
+// the profiles.ini of firefoxDir, or else of a directory named like one.
+func defaultProfileDir(firefoxDir string) (string, error) {
+	profilesINI, err := ReadProfilesINI(firefoxDir)
+	if err == nil {
+		profile, err := profilesINI.DefaultProfile()
+		if err != nil {
+			return "", err
+		}
+		return profilesINI.ProfileDir(profile), nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+
+	profiles, err := scanProfiles(firefoxDir)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range []string{"default-release", "default"} {
+		for _, profile := range profiles {
+			if profile.Name == name {
+				return profile.Dir, nil
+			}
+		}
+	}
+
+	return "", errors.New("Unable to locate default profile")
+}
+
+// scanProfiles returns the directories with a cookie file in firefoxDir and
+// in its Profiles directory, where Firefox on Windows and macOS keeps them.
+func scanProfiles(firefoxDir string) ([]kooky.Profile, error) {
+	var profiles []kooky.Profile
+	for _, dir := range []string{firefoxDir, filepath.Join(firefoxDir, "Profiles")} {
+		entries, err := ioutil.ReadDir(dir)
+		if os.IsNotExist(err) && dir != firefoxDir {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			profileDir := filepath.Join(dir, entry.Name())
+			file := filepath.Join(profileDir, cookieFileName)
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+
+			profiles = append(profiles, kooky.Profile{
+				ID:         entry.Name(),
+				Name:       profileName(entry.Name()),
+				Dir:        profileDir,
+				CookieFile: file,
+			})
+		}
+	}
+
+	return profiles, nil
+}
+
+// profileName returns the name of the profile in directory dirName, which
+// Firefox names "<salt>.<name>", e.g. "q1w2e3r4.default-release".
+func profileName(dirName string) string {
+	if i := strings.Index(dirName, "."); i >= 0 {
+		return dirName[i+1:]
+	}
+	return dirName
+}
@@ -0,0 +1,236 @@
+package firefox
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// http://kb.mozillazine.org/Profiles.ini_file
+// https://searchfox.org/mozilla-central/source/toolkit/profile/nsToolkitProfileService.cpp
+
+const (
+	profilesINIFile = "profiles.ini"
+	installsINIFile = "installs.ini"
+)
+
+// ProfilesINI is the list of profiles Firefox keeps in profiles.ini, along
+// with the default profile of each installation from profiles.ini and
+// installs.ini.
+type ProfilesINI struct {
+	// Dir is the directory holding profiles.ini, against which relative
+	// profile paths are resolved.
+	Dir string
+
+	Profiles []ProfileSection
+	Installs []InstallSection
+}
+
+// ProfileSection is a [ProfileN] section of profiles.ini.
+type ProfileSection struct {
+	Name string
+
+	// Path is the profile directory, relative to the directory of
+	// profiles.ini if IsRelative is set.
+	Path       string
+	IsRelative bool
+
+	// Default marks the default profile of Firefox versions predating
+	// per-installation profiles.
+	Default bool
+}
+
+// InstallSection is an [Install<hash>] section of profiles.ini or a
+// section of installs.ini, naming the default profile of one installation.
+type InstallSection struct {
+	Hash string
+
+	// Default is the Path of the default profile of the installation.
+	Default string
+
+	// Locked is set if the installation may not switch to another profile.
+	Locked bool
+}
+
+// ReadProfilesINI reads profiles.ini and, if present, installs.ini from the Firefox directory dir.
+func ReadProfilesINI(dir string) (*ProfilesINI, error) {
+	f, err := os.Open(filepath.Join(dir, profilesINIFile))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles, err := ParseProfilesINI(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", profilesINIFile, err)
+	}
+	profiles.Dir = dir
+
+	installs, err := os.Open(filepath.Join(dir, installsINIFile))
+	if os.IsNotExist(err) {
+		return profiles, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer installs.Close()
+
+	if err := profiles.parseInstallsINI(installs); err != nil {
+		return nil, fmt.Errorf("%s: %v", installsINIFile, err)
+	}
+	return profiles, nil
+}
+
+// ParseProfilesINI parses the content of a profiles.ini file.
+func ParseProfilesINI(r io.Reader) (*ProfilesINI, error) {
+	sections, err := parseINI(r)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := &ProfilesINI{}
+	for _, section := range sections {
+		switch {
+		case strings.HasPrefix(section.name, "Profile"):
+			path := section.values["Path"]
+			if path == "" {
+				continue
+			}
+			profiles.Profiles = append(profiles.Profiles, ProfileSection{
+				Name:       section.values["Name"],
+				Path:       path,
+				IsRelative: section.values["IsRelative"] == "1",
+				Default:    section.values["Default"] == "1",
+			})
+		case strings.HasPrefix(section.name, "Install"):
+			profiles.addInstall(strings.TrimPrefix(section.name, "Install"), section)
+		}
+	}
+
+	return profiles, nil
+}
+
+// parseInstallsINI adds the installations of installs.ini that profiles.ini
+// does not already list.
+func (profiles *ProfilesINI) parseInstallsINI(r io.Reader) error {
+	sections, err := parseINI(r)
+	if err != nil {
+		return err
+	}
+
+	for _, section := range sections {
+		profiles.addInstall(section.name, section)
+	}
+	return nil
+}
+
+func (profiles *ProfilesINI) addInstall(hash string, section iniSection) {
+	for _, install := range profiles.Installs {
+		if install.Hash == hash {
+			return
+		}
+	}
+
+	if section.values["Default"] == "" {
+		return
+	}
+	profiles.Installs = append(profiles.Installs, InstallSection{
+		Hash:    hash,
+		Default: section.values["Default"],
+		Locked:  section.values["Locked"] == "1",
+	})
+}
+
+// DefaultProfile returns the profile Firefox starts with: the default of an
+// installation, preferring locked ones, or else the profile marked Default,
+// or else the only profile.
+func (profiles *ProfilesINI) DefaultProfile() (ProfileSection, error) {
+	for _, locked := range []bool{true, false} {
+		for _, install := range profiles.Installs {
+			if install.Locked != locked {
+				continue
+			}
+			if profile, ok := profiles.findPath(install.Default); ok {
+				return profile, nil
+			}
+		}
+	}
+
+	for _, profile := range profiles.Profiles {
+		if profile.Default {
+			return profile, nil
+		}
+	}
+
+	if len(profiles.Profiles) == 1 {
+		return profiles.Profiles[0], nil
+	}
+
+	return ProfileSection{}, errors.New("Unable to locate default profile")
+}
+
+func (profiles *ProfilesINI) findPath(path string) (ProfileSection, bool) {
+	for _, profile := range profiles.Profiles {
+		if profile.Path == path {
+			return profile, true
+		}
+	}
+
+	return ProfileSection{}, false
+}
+
+// ProfileDir returns the absolute directory of profile.
+func (profiles *ProfilesINI) ProfileDir(profile ProfileSection) string {
+	path := filepath.FromSlash(profile.Path)
+	if profile.IsRelative {
+		return filepath.Join(profiles.Dir, path)
+	}
+	return path
+}
+
+type iniSection struct {
+	name   string
+	values map[string]string
+}
+
+// parseINI parses the sections of an ini file in order. Keys outside of a
+// section are ignored.
+func parseINI(r io.Reader) ([]iniSection, error) {
+	var sections []iniSection
+
+	scanner := bufio.NewScanner(r)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case line == "", line[0] == ';', line[0] == '#':
+		case line[0] == '[':
+			if line[len(line)-1] != ']' {
+				return nil, fmt.Errorf("line %d: unterminated section header", lineNumber)
+			}
+			sections = append(sections, iniSection{
+				name:   line[1 : len(line)-1],
+				values: make(map[string]string),
+			})
+		default:
+			i := strings.IndexByte(line, '=')
+			if i < 0 {
+				return nil, fmt.Errorf("line %d: expected key=value", lineNumber)
+			}
+			if len(sections) == 0 {
+				continue
+			}
+			key := strings.TrimSpace(line[:i])
+			sections[len(sections)-1].values[key] = strings.TrimSpace(line[i+1:])
+		}
+	}
+
+	return sections, scanner.Err()
+}
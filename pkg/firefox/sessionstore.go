@@ -0,0 +1,181 @@
+package firefox
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kgoins/kooky/internal/storefs"
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// Firefox keeps session cookies, which are not written to cookies.sqlite,
+// in its session store: sessionstore-backups/recovery.jsonlz4 while running
+// and sessionstore.jsonlz4 after a clean exit.
+var sessionStoreFiles = []string{
+	"sessionstore-backups/recovery.jsonlz4",
+	"sessionstore.jsonlz4",
+}
+
+type sessionStore struct {
+	Cookies []sessionCookie `json:"cookies"`
+	Windows []struct {
+		Cookies []sessionCookie `json:"cookies"`
+	} `json:"windows"`
+}
+
+type sessionCookie struct {
+	Host             string                  `json:"host"`
+	Name             string                  `json:"name"`
+	Value            string                  `json:"value"`
+	Path             string                  `json:"path"`
+	Secure           bool                    `json:"secure"`
+	HttpOnly         bool                    `json:"httponly"`
+	Expiry           int64                   `json:"expiry"`
+	SameSite         int                     `json:"sameSite"`
+	OriginAttributes sessionOriginAttributes `json:"originAttributes"`
+}
+
+type sessionOriginAttributes struct {
+	UserContextID     int    `json:"userContextId"`
+	PrivateBrowsingID int    `json:"privateBrowsingId"`
+	FirstPartyDomain  string `json:"firstPartyDomain"`
+	PartitionKey      string `json:"partitionKey"`
+}
+
+// SessionStoreSource is the Source of cookies read from the session store.
+const SessionStoreSource = "sessionstore"
+
+// FromSessionStore reports whether cookie was read from a session store
+// file rather than cookies.sqlite. It can be used as a kooky.Filter.
+func FromSessionStore(cookie *kooky.Cookie) bool {
+	return cookie.Browser == "firefox" && cookie.Source == SessionStoreSource
+}
+
+// findSessionStore returns the session store of the profile holding the
+// cookie file filename, or "" if it has none.
+func findSessionStore(files storefs.FS, filename string) string {
+	profileDir := files.Dir(filename)
+	for _, name := range sessionStoreFiles {
+		path := files.Join(profileDir, name)
+		if files.Exists(path) {
+			return path
+		}
+	}
+
+	return ""
+}
+
+// ReadSessionStoreCookies reads the session cookies from the Firefox session
+// store file filename, e.g. recovery.jsonlz4, filtered by the input filters.
+func (reader CookieReader) ReadSessionStoreCookies(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	files := storefs.OS()
+	cookies, _, err := readSessionStore(files, filename, containerNames(files, profileDirOfSessionStore(files, filename)), true, filters)
+	return cookies, err
+}
+
+// readSessionStore reads the cookies of the session store filename. In strict
+// mode the first cookie that fails aborts the read.
+func readSessionStore(files storefs.FS, filename string, containers map[int]string, strict bool, filters []kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	data, err := files.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The session stores of Firefox before 56 are plain JSON.
+	if bytes.HasPrefix(data, []byte(mozLz4Magic)) {
+		if data, err = DecodeMozLz4(data); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var store sessionStore
+	if err := json.Unmarshal(data, &store); err != nil {
+		return nil, nil, fmt.Errorf("%s: %v", filepath.Base(filename), err)
+	}
+
+	sessionCookies := store.Cookies
+	for _, window := range store.Windows {
+		sessionCookies = append(sessionCookies, window.Cookies...)
+	}
+
+	var cookies []*kooky.Cookie
+	var cookieErrors []kooky.CookieError
+	for i, sc := range sessionCookies {
+		// Cookies may have no name, but not no host.
+		if sc.Host == "" {
+			cookieErr := kooky.CookieError{RowID: int64(i), Name: sc.Name, Stage: kooky.StageParse, Err: fmt.Errorf("session store cookie %d has no host", i)}
+			if strict {
+				return nil, nil, cookieErr
+			}
+			cookieErrors = append(cookieErrors, cookieErr)
+			continue
+		}
+
+		cookie := &kooky.Cookie{
+			Domain:   sc.Host,
+			Name:     sc.Name,
+			Path:     sc.Path,
+			Value:    sc.Value,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HttpOnly,
+			SameSite: firefoxSameSite(int64(sc.SameSite)),
+			HostOnly: !strings.HasPrefix(sc.Host, "."),
+			OriginAttributes: kooky.OriginAttributes{
+				UserContextID:     sc.OriginAttributes.UserContextID,
+				PrivateBrowsingID: sc.OriginAttributes.PrivateBrowsingID,
+				FirstPartyDomain:  sc.OriginAttributes.FirstPartyDomain,
+				PartitionKey:      sc.OriginAttributes.PartitionKey,
+			},
+			Browser: "firefox",
+			File:    filename,
+			Source:  SessionStoreSource,
+		}
+		if sc.Expiry != 0 {
+			cookie.Expires = time.Unix(sc.Expiry, 0)
+		}
+		cookie.Container = containers[cookie.OriginAttributes.UserContextID]
+		cookie.Profile = files.Base(profileDirOfSessionStore(files, filename))
+
+		if !kooky.FilterCookie(cookie, filters...) {
+			continue
+		}
+		cookies = append(cookies, cookie)
+	}
+
+	return cookies, cookieErrors, nil
+}
+
+// profileDirOfSessionStore returns the profile directory of the session
+// store filename, which is either in it or in its sessionstore-backups.
+func profileDirOfSessionStore(files storefs.FS, filename string) string {
+	dir := files.Dir(filename)
+	if files.Base(dir) == "sessionstore-backups" {
+		return files.Dir(dir)
+	}
+	return dir
+}
+
+// cookieKey identifies a cookie within a profile.
+type cookieKey struct {
+	domain, name, path string
+	originAttributes   kooky.OriginAttributes
+}
+
+func keyOf(cookie *kooky.Cookie) cookieKey {
+	return cookieKey{cookie.Domain, cookie.Name, cookie.Path, cookie.OriginAttributes}
+}
+
+// mergeCookies appends the session store cookies not already read from
+// cookies.sqlite, whose keys are in persisted, to cookies.
+func mergeCookies(cookies []*kooky.Cookie, sessionCookies []*kooky.Cookie, persisted map[cookieKey]bool) []*kooky.Cookie {
+	for _, cookie := range sessionCookies {
+		if !persisted[keyOf(cookie)] {
+			cookies = append(cookies, cookie)
+		}
+	}
+	return cookies
+}
@@ -1,7 +1,11 @@
 package kooky
 
 import (
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -19,6 +23,109 @@ type Cookie struct {
 	HttpOnly bool
 	Creation time.Time
 	Value    string
+
+	LastAccess   time.Time
+	SameSite     SameSite
+	HostOnly     bool // only sent to Domain itself, not to its subdomains
+	Persistent   bool // kept across browser restarts
+	Priority     Priority
+	SourceScheme SourceScheme
+	SourcePort   int // port the cookie was set from, 0 if unknown
+
+	// OriginAttributes isolate the cookie from cookies of the same host in
+	// other contexts. Container is the name of its Firefox container, if any.
+	OriginAttributes OriginAttributes
+	Container        string
+
+	// RFC 2965 attributes, which Safari and libwww-perl cookie files keep.
+	Comment    string
+	CommentURL string
+	Ports      []int
+
+	// Provenance of the cookie.
+	Browser string // name of the browser, e.g. "chrome"
+	Profile string // name of the browser profile, if any
+	File    string // path of the cookie store the cookie was read from
+	Source  string // store within the profile, if not the main one, e.g. "sessionstore"
+}
+
+// OriginAttributes are the Firefox origin attributes of a cookie, which
+// keep containers, private browsing and first-party isolated or partitioned
+// contexts apart.
+type OriginAttributes struct {
+	UserContextID     int    // container, 0 outside of containers
+	PrivateBrowsingID int    // non-zero in private browsing
+	FirstPartyDomain  string // set under first-party isolation
+	PartitionKey      string // set for partitioned (third-party) cookies
+}
+
+// SameSite is the SameSite attribute of a cookie.
+type SameSite int
+
+// SameSite values, normalized across browsers.
+const (
+	SameSiteUnspecified SameSite = iota
+	SameSiteNone
+	SameSiteLax
+	SameSiteStrict
+)
+
+func (s SameSite) String() string {
+	switch s {
+	case SameSiteNone:
+		return "None"
+	case SameSiteLax:
+		return "Lax"
+	case SameSiteStrict:
+		return "Strict"
+	default:
+		return "Unspecified"
+	}
+}
+
+// Priority is the Chrome-specific Priority attribute of a cookie.
+type Priority int
+
+// Priority values.
+const (
+	PriorityUnspecified Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "Low"
+	case PriorityMedium:
+		return "Medium"
+	case PriorityHigh:
+		return "High"
+	default:
+		return "Unspecified"
+	}
+}
+
+// SourceScheme records whether a cookie was set over a secure connection.
+type SourceScheme int
+
+// SourceScheme values.
+const (
+	SourceSchemeUnset SourceScheme = iota
+	SourceSchemeNonSecure
+	SourceSchemeSecure
+)
+
+func (s SourceScheme) String() string {
+	switch s {
+	case SourceSchemeNonSecure:
+		return "NonSecure"
+	case SourceSchemeSecure:
+		return "Secure"
+	default:
+		return "Unset"
+	}
 }
 
 // HttpCookie returns an http.Cookie equivalent to this Cookie.
@@ -32,9 +139,47 @@ func (c Cookie) HttpCookie() http.Cookie {
 	hc.HttpOnly = c.HttpOnly
 	hc.Value = c.Value
 
+	switch c.SameSite {
+	case SameSiteNone:
+		hc.SameSite = http.SameSiteNoneMode
+	case SameSiteLax:
+		hc.SameSite = http.SameSiteLaxMode
+	case SameSiteStrict:
+		hc.SameSite = http.SameSiteStrictMode
+	}
+
+	if !c.Expires.IsZero() {
+		hc.RawExpires = c.Expires.UTC().Format(http.TimeFormat)
+	}
+	hc.Raw = hc.String()
+
 	return hc
 }
 
+// URL returns the URL of the cookie's origin and path, to which a browser
+// would send it. The scheme is https for secure cookies and those set over
+// https, and http for others. The port is the cookie's source port, unless
+// that is unknown or the default port 80 or 443.
+func (c Cookie) URL() string {
+	scheme := "http"
+	if c.Secure || c.SourceScheme == SourceSchemeSecure {
+		scheme = "https"
+	}
+
+	u := url.URL{
+		Scheme: scheme,
+		Host:   strings.TrimPrefix(c.Domain, "."),
+		Path:   c.Path,
+	}
+	if c.SourcePort > 0 && c.SourcePort != 80 && c.SourcePort != 443 {
+		u.Host = net.JoinHostPort(u.Host, strconv.Itoa(c.SourcePort))
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String()
+}
+
 // FindCookie returns a cookie matching the input domain and name from a list of Cookies
 func FindCookie(domain string, name string, cookies []*Cookie) *Cookie {
 	for _, cookie := range cookies {
@@ -0,0 +1,50 @@
+package kooky
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestHttpCookie(t *testing.T) {
+	cookie := Cookie{
+		Domain:   ".example.com",
+		Name:     "id",
+		Path:     "/",
+		Expires:  time.Date(2038, 01, 17, 19, 14, 07, 0, time.UTC),
+		Secure:   true,
+		HttpOnly: true,
+		Value:    "abc",
+		SameSite: SameSiteLax,
+	}
+
+	hc := cookie.HttpCookie()
+	if hc.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Want SameSite=%v; got %v", http.SameSiteLaxMode, hc.SameSite)
+	}
+	if want := "Sun, 17 Jan 2038 19:14:07 GMT"; hc.RawExpires != want {
+		t.Errorf("Want RawExpires=%q; got %q", want, hc.RawExpires)
+	}
+	if want := "id=abc; Path=/; Domain=example.com; Expires=Sun, 17 Jan 2038 19:14:07 GMT; HttpOnly; Secure; SameSite=Lax"; hc.Raw != want {
+		t.Errorf("Want Raw=%q; got %q", want, hc.Raw)
+	}
+}
+
+func TestCookieURL(t *testing.T) {
+	tests := []struct {
+		cookie Cookie
+		want   string
+	}{
+		{Cookie{Domain: ".example.com", Path: "/"}, "http://example.com/"},
+		{Cookie{Domain: "www.example.com", Path: "/app", Secure: true}, "https://www.example.com/app"},
+		{Cookie{Domain: "example.com", SourceScheme: SourceSchemeSecure}, "https://example.com/"},
+		{Cookie{Domain: "localhost", Path: "/", Secure: true, SourceScheme: SourceSchemeNonSecure}, "https://localhost/"},
+		{Cookie{Domain: "localhost", Path: "/", SourceScheme: SourceSchemeNonSecure, SourcePort: 8080}, "http://localhost:8080/"},
+		{Cookie{Domain: ".example.com", Path: "/", SourceScheme: SourceSchemeSecure, SourcePort: 443}, "https://example.com/"},
+	}
+	for _, test := range tests {
+		if got := test.cookie.URL(); got != test.want {
+			t.Errorf("Want %s for %+v; got %s", test.want, test.cookie, got)
+		}
+	}
+}
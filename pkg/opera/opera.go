@@ -0,0 +1,9 @@
+package opera
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Opera browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("opera")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}
@@ -0,0 +1,445 @@
+package safari
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf16"
+)
+
+// Binary property lists are described in CFBinaryPList.c:
+// https://opensource.apple.com/source/CF/CF-1153.18/CFBinaryPList.c
+
+const (
+	bplistMagic       = "bplist00"
+	bplistTrailerSize = 32
+
+	// bplistMaxDepth bounds the nesting of containers, which also stops
+	// cyclic references.
+	bplistMaxDepth = 32
+
+	// bplistMaxVisits bounds the number of objects decoded per object in
+	// the list. Objects referenced more than once are decoded each time,
+	// which containers referencing the same child twice would otherwise
+	// make exponential.
+	bplistMaxVisits = 16
+)
+
+// bplist decodes the objects of a binary property list.
+type bplist struct {
+	data          []byte
+	offsets       []uint64
+	objectRefSize int
+
+	// visits counts the objects decoded so far.
+	visits int
+}
+
+// decodeBplist decodes a binary property list. Dictionaries are decoded as
+// map[string]interface{}, arrays as []interface{}, integers as int64, reals
+// as float64, dates as time.Time, data as []byte and UIDs as uint64.
+func decodeBplist(data []byte) (interface{}, error) {
+	if len(data) < len(bplistMagic)+bplistTrailerSize || string(data[:len(bplistMagic)]) != bplistMagic {
+		return nil, errors.New("not a binary property list")
+	}
+
+	trailer := data[len(data)-bplistTrailerSize:]
+	offsetIntSize := int(trailer[6])
+	objectRefSize := int(trailer[7])
+	numObjects := binary.BigEndian.Uint64(trailer[8:])
+	topObject := binary.BigEndian.Uint64(trailer[16:])
+	offsetTableOffset := binary.BigEndian.Uint64(trailer[24:])
+
+	if offsetIntSize < 1 || offsetIntSize > 8 || objectRefSize < 1 || objectRefSize > 8 {
+		return nil, errors.New("invalid binary property list trailer")
+	}
+	end := uint64(len(data) - bplistTrailerSize)
+	if offsetTableOffset > end || numObjects > (end-offsetTableOffset)/uint64(offsetIntSize) || topObject >= numObjects {
+		return nil, errors.New("invalid binary property list offset table")
+	}
+
+	p := &bplist{data: data[:end], objectRefSize: objectRefSize}
+	p.offsets = make([]uint64, numObjects)
+	for i := range p.offsets {
+		start := offsetTableOffset + uint64(i*offsetIntSize)
+		p.offsets[i] = readUint(data[start : start+uint64(offsetIntSize)])
+	}
+
+	return p.object(topObject, 0)
+}
+
+// readUint reads a big-endian unsigned integer of up to 8 bytes.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+// object decodes the object with reference ref.
+func (p *bplist) object(ref uint64, depth int) (interface{}, error) {
+	if ref >= uint64(len(p.offsets)) {
+		return nil, fmt.Errorf("object reference %d out of range", ref)
+	}
+	if depth > bplistMaxDepth {
+		return nil, errors.New("binary property list nested too deeply")
+	}
+	p.visits++
+	if p.visits > len(p.offsets)*bplistMaxVisits {
+		return nil, errors.New("binary property list references objects too often")
+	}
+
+	offset := p.offsets[ref]
+	if offset >= uint64(len(p.data)) {
+		return nil, fmt.Errorf("object %d out of range", ref)
+	}
+	marker := p.data[offset]
+	kind, info := marker>>4, int(marker&0x0f)
+	offset++
+
+	switch kind {
+	case 0x0:
+		switch marker {
+		case 0x00:
+			return nil, nil
+		case 0x08:
+			return false, nil
+		case 0x09:
+			return true, nil
+		}
+	case 0x1:
+		b, err := p.bytes(offset, 1<<uint(info))
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 8 {
+			// 128 bit integers hold values that fit in their low 8 bytes.
+			b = b[len(b)-8:]
+		}
+		return int64(readUint(b)), nil
+	case 0x2:
+		b, err := p.bytes(offset, 1<<uint(info))
+		if err != nil {
+			return nil, err
+		}
+		switch len(b) {
+		case 4:
+			return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+		case 8:
+			return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+		}
+	case 0x3:
+		b, err := p.bytes(offset, 8)
+		if err != nil {
+			return nil, err
+		}
+		return safariCookieDate(math.Float64frombits(binary.BigEndian.Uint64(b))), nil
+	case 0x4, 0x5, 0x6:
+		count, offset, err := p.count(info, offset)
+		if err != nil {
+			return nil, err
+		}
+		if kind == 0x6 {
+			b, err := p.bytes(offset, count*2)
+			if err != nil {
+				return nil, err
+			}
+			units := make([]uint16, count)
+			for i := range units {
+				units[i] = binary.BigEndian.Uint16(b[2*i:])
+			}
+			return string(utf16.Decode(units)), nil
+		}
+		b, err := p.bytes(offset, count)
+		if err != nil {
+			return nil, err
+		}
+		if kind == 0x4 {
+			return append([]byte(nil), b...), nil
+		}
+		return string(b), nil
+	case 0x8:
+		b, err := p.bytes(offset, info+1)
+		if err != nil {
+			return nil, err
+		}
+		return readUint(b), nil
+	case 0xa:
+		count, offset, err := p.count(info, offset)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(offset, count)
+		if err != nil {
+			return nil, err
+		}
+		array := make([]interface{}, count)
+		for i, ref := range refs {
+			if array[i], err = p.object(ref, depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return array, nil
+	case 0xd:
+		count, offset, err := p.count(info, offset)
+		if err != nil {
+			return nil, err
+		}
+		refs, err := p.refs(offset, 2*count)
+		if err != nil {
+			return nil, err
+		}
+		dict := make(map[string]interface{}, count)
+		for i := 0; i < count; i++ {
+			key, err := p.object(refs[i], depth+1)
+			if err != nil {
+				return nil, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("dictionary key of type %T", key)
+			}
+			if dict[name], err = p.object(refs[count+i], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+
+	return nil, fmt.Errorf("unsupported object marker %#02x", marker)
+}
+
+// count returns the element count of an object whose marker holds info,
+// and the offset of its content: counts of 15 or more follow the marker as
+// an integer object.
+func (p *bplist) count(info int, offset uint64) (int, uint64, error) {
+	if info != 0x0f {
+		return info, offset, nil
+	}
+
+	b, err := p.bytes(offset, 1)
+	if err != nil {
+		return 0, 0, err
+	}
+	if b[0]>>4 != 0x1 {
+		return 0, 0, errors.New("invalid object count")
+	}
+	size := 1 << uint(b[0]&0x0f)
+	b, err = p.bytes(offset+1, size)
+	if err != nil {
+		return 0, 0, err
+	}
+	count := readUint(b)
+	if count > uint64(len(p.data)) {
+		return 0, 0, errors.New("object count out of range")
+	}
+	return int(count), offset + 1 + uint64(size), nil
+}
+
+// refs reads count object references at offset.
+func (p *bplist) refs(offset uint64, count int) ([]uint64, error) {
+	b, err := p.bytes(offset, count*p.objectRefSize)
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]uint64, count)
+	for i := range refs {
+		refs[i] = readUint(b[i*p.objectRefSize : (i+1)*p.objectRefSize])
+	}
+	return refs, nil
+}
+
+func (p *bplist) bytes(offset uint64, n int) ([]byte, error) {
+	if n < 0 || offset > uint64(len(p.data)) || uint64(n) > uint64(len(p.data))-offset {
+		return nil, errors.New("binary property list object out of range")
+	}
+	return p.data[offset : offset+uint64(n)], nil
+}
+
+// bplistEncoder lays out the objects of a binary property list. Containers
+// are numbered before their elements, and the keys of a dictionary before
+// its values, as CoreFoundation writes them.
+type bplistEncoder struct {
+	objects  []interface{}
+	children [][]int
+}
+
+// encodeBplist encodes v as a binary property list, taking the types
+// decodeBplist decodes to. Dictionary keys are written in sorted order.
+func encodeBplist(v interface{}) ([]byte, error) {
+	e := &bplistEncoder{}
+	if _, err := e.add(v); err != nil {
+		return nil, err
+	}
+	objectRefSize := uintSize(uint64(len(e.objects) - 1))
+
+	buf := []byte(bplistMagic)
+	offsets := make([]uint64, len(e.objects))
+	for i, object := range e.objects {
+		offsets[i] = uint64(len(buf))
+		buf = e.appendObject(buf, object, e.children[i], objectRefSize)
+	}
+
+	offsetTableOffset := uint64(len(buf))
+	offsetIntSize := uintSize(offsets[len(offsets)-1])
+	for _, offset := range offsets {
+		buf = appendUint(buf, offset, offsetIntSize)
+	}
+
+	trailer := make([]byte, bplistTrailerSize)
+	trailer[6] = byte(offsetIntSize)
+	trailer[7] = byte(objectRefSize)
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(e.objects)))
+	binary.BigEndian.PutUint64(trailer[24:], offsetTableOffset)
+	return append(buf, trailer...), nil
+}
+
+// add numbers v and the objects it contains, returning its reference.
+func (e *bplistEncoder) add(v interface{}) (int, error) {
+	ref := len(e.objects)
+	e.objects = append(e.objects, v)
+	e.children = append(e.children, nil)
+
+	var elements []interface{}
+	switch v := v.(type) {
+	case nil, bool, int, int64, uint64, float64, time.Time, []byte, string:
+		return ref, nil
+	case []interface{}:
+		elements = v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			elements = append(elements, key)
+		}
+		for _, key := range keys {
+			elements = append(elements, v[key])
+		}
+	default:
+		return 0, fmt.Errorf("unsupported property list type %T", v)
+	}
+
+	children := make([]int, len(elements))
+	for i, element := range elements {
+		child, err := e.add(element)
+		if err != nil {
+			return 0, err
+		}
+		children[i] = child
+	}
+	e.children[ref] = children
+	return ref, nil
+}
+
+// appendObject appends the encoding of object, whose elements have the
+// references children.
+func (e *bplistEncoder) appendObject(buf []byte, object interface{}, children []int, objectRefSize int) []byte {
+	switch v := object.(type) {
+	case nil:
+		return append(buf, 0x00)
+	case bool:
+		if v {
+			return append(buf, 0x09)
+		}
+		return append(buf, 0x08)
+	case int:
+		return appendInt(buf, int64(v))
+	case int64:
+		return appendInt(buf, v)
+	case uint64:
+		size := intSize(v)
+		return appendUint(append(buf, 0x80|byte(size-1)), v, size)
+	case float64:
+		return appendUint(append(buf, 0x23), math.Float64bits(v), 8)
+	case time.Time:
+		return appendUint(append(buf, 0x33), math.Float64bits(macCookieDate(v)), 8)
+	case []byte:
+		return append(appendCount(buf, 0x4, len(v)), v...)
+	case string:
+		if isASCII(v) {
+			return append(appendCount(buf, 0x5, len(v)), v...)
+		}
+		units := utf16.Encode([]rune(v))
+		buf = appendCount(buf, 0x6, len(units))
+		for _, unit := range units {
+			buf = appendUint(buf, uint64(unit), 2)
+		}
+		return buf
+	}
+
+	kind := byte(0xa)
+	count := len(children)
+	if _, ok := object.(map[string]interface{}); ok {
+		kind, count = 0xd, count/2
+	}
+	buf = appendCount(buf, kind, count)
+	for _, child := range children {
+		buf = appendUint(buf, uint64(child), objectRefSize)
+	}
+	return buf
+}
+
+// appendInt appends an integer object. Negative integers take 8 bytes.
+func appendInt(buf []byte, v int64) []byte {
+	size := 8
+	if v >= 0 {
+		size = intSize(uint64(v))
+	}
+	info := byte(0)
+	for 1<<info < size {
+		info++
+	}
+	return appendUint(append(buf, 0x10|info), uint64(v), size)
+}
+
+// appendCount appends the marker of an object of kind with count elements,
+// the inverse of bplist.count.
+func appendCount(buf []byte, kind byte, count int) []byte {
+	if count < 0x0f {
+		return append(buf, kind<<4|byte(count))
+	}
+	return appendInt(append(buf, kind<<4|0x0f), int64(count))
+}
+
+// appendUint appends v as a big-endian unsigned integer of size bytes.
+func appendUint(buf []byte, v uint64, size int) []byte {
+	for i := size - 1; i >= 0; i-- {
+		buf = append(buf, byte(v>>(8*uint(i))))
+	}
+	return buf
+}
+
+// uintSize returns the number of bytes v takes.
+func uintSize(v uint64) int {
+	size := 1
+	for v > 0xff {
+		v >>= 8
+		size++
+	}
+	return size
+}
+
+// intSize returns the size of integer objects holding v: 1, 2, 4 or 8 bytes.
+func intSize(v uint64) int {
+	size := 1
+	for size < uintSize(v) {
+		size *= 2
+	}
+	return size
+}
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= 0x80 {
+			return false
+		}
+	}
+	return true
+}
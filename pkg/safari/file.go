@@ -0,0 +1,263 @@
+package safari
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// A binarycookies file is laid out as:
+//
+//	"cook", page count and page sizes   big-endian
+//	pages                               little-endian
+//	checksum                            big-endian, 4 bytes
+//	footer                              8 bytes
+//	binary property list                NSHTTPCookieAcceptPolicy
+
+const (
+	fileMagic   = "cook"
+	footerMagic = "\x07\x17\x20\x05\x00\x00\x00\x4b"
+)
+
+// ErrNotIntact is returned when a file is truncated or its checksum does
+// not match its pages, as happens when it is copied while Safari writes it.
+var ErrNotIntact = errors.New("binarycookies file is truncated or corrupted")
+
+// errTruncated is returned when a page extends past the end of the file,
+// after which no further page can be located.
+var errTruncated = errors.New("truncated page")
+
+// File is the content of a binarycookies file.
+type File struct {
+	Cookies []*kooky.Cookie
+
+	// Checksum is the checksum stored after the pages, ComputedChecksum the
+	// one computed from them: the sum of every fourth byte of each page.
+	Checksum         uint32
+	ComputedChecksum uint32
+
+	// Intact is set if the file is complete, with its footer, and its
+	// checksum matches.
+	Intact bool
+
+	// Metadata is the property list following the footer, and AcceptPolicy
+	// the NSHTTPCookieAcceptPolicy it holds.
+	Metadata     map[string]interface{}
+	AcceptPolicy AcceptPolicy
+}
+
+// AcceptPolicy is the cookie accept policy of a cookie store, an
+// NSHTTPCookieAcceptPolicy.
+type AcceptPolicy int
+
+const (
+	// AcceptPolicyUnknown is reported for files without an accept policy.
+	AcceptPolicyUnknown AcceptPolicy = -1
+
+	AcceptPolicyAlways                     AcceptPolicy = 0
+	AcceptPolicyNever                      AcceptPolicy = 1
+	AcceptPolicyOnlyFromMainDocumentDomain AcceptPolicy = 2
+)
+
+const acceptPolicyKey = "NSHTTPCookieAcceptPolicy"
+
+func (policy AcceptPolicy) String() string {
+	switch policy {
+	case AcceptPolicyUnknown:
+		return "unknown"
+	case AcceptPolicyAlways:
+		return "always"
+	case AcceptPolicyNever:
+		return "never"
+	case AcceptPolicyOnlyFromMainDocumentDomain:
+		return "only from main document domain"
+	}
+	return fmt.Sprintf("AcceptPolicy(%d)", int(policy))
+}
+
+// BlocksThirdPartyCookies reports whether the store refuses cookies from
+// domains other than that of the main document.
+func (policy AcceptPolicy) BlocksThirdPartyCookies() bool {
+	return policy == AcceptPolicyNever || policy == AcceptPolicyOnlyFromMainDocumentDomain
+}
+
+// ReadFile reads the binarycookies file filename. A file that is not
+// intact is read as far as possible, skipping the cookies that cannot be
+// read; check File.Intact.
+func ReadFile(filename string) (*File, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	file, _, err := parseFile(data, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, cookie := range file.Cookies {
+		cookie.File = filename
+	}
+	return file, nil
+}
+
+// parseFile parses the binarycookies file data. In strict mode the first
+// cookie or page that fails aborts the parse; otherwise they are skipped
+// and reported as cookie errors.
+func parseFile(data []byte, strict bool) (*File, []kooky.CookieError, error) {
+	r := bytes.NewReader(data)
+
+	var header fileHeader
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return nil, nil, fmt.Errorf("error reading header: %v", err)
+	}
+	if string(header.Magic[:]) != fileMagic {
+		return nil, nil, fmt.Errorf("expected first 4 bytes to be %q; got %q", fileMagic, string(header.Magic[:]))
+	}
+	if header.NumPages < 0 || int64(header.NumPages)*4 > int64(r.Len()) {
+		return nil, nil, fmt.Errorf("error reading page sizes: %v", io.ErrUnexpectedEOF)
+	}
+
+	pageSizes := make([]int32, header.NumPages)
+	if err := binary.Read(r, binary.BigEndian, &pageSizes); err != nil {
+		return nil, nil, fmt.Errorf("error reading page sizes: %v", err)
+	}
+
+	file := &File{AcceptPolicy: AcceptPolicyUnknown}
+	p := pageReader{strict: strict}
+	offset := len(data) - r.Len()
+	complete := true
+	for i, pageSize := range pageSizes {
+		if pageSize < 0 || int(pageSize) > len(data)-offset {
+			err := fmt.Errorf("%w: %v", errTruncated, io.ErrUnexpectedEOF)
+			if strict {
+				return nil, nil, fmt.Errorf("error reading page %d: %v", i, err)
+			}
+			p.errors = append(p.errors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: fmt.Errorf("page %d: %w", i, err)})
+			complete = false
+			break
+		}
+
+		page := data[offset : offset+int(pageSize)]
+		offset += int(pageSize)
+		file.ComputedChecksum += pageChecksum(page)
+
+		if err := p.readPage(page); err != nil {
+			if strict {
+				return nil, nil, fmt.Errorf("error reading page %d: %v", i, err)
+			}
+			p.errors = append(p.errors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: fmt.Errorf("page %d: %w", i, err)})
+		}
+	}
+	file.Cookies = p.cookies
+
+	if complete {
+		if offset+4 > len(data) {
+			err := fmt.Errorf("error reading checksum: %v", io.ErrUnexpectedEOF)
+			if strict {
+				return nil, nil, err
+			}
+			p.errors = append(p.errors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: err})
+			complete = false
+		} else {
+			file.Checksum = binary.BigEndian.Uint32(data[offset:])
+			offset += 4
+		}
+	}
+
+	hasFooter := offset+len(footerMagic) <= len(data) && string(data[offset:offset+len(footerMagic)]) == footerMagic
+	file.Intact = complete && hasFooter && file.Checksum == file.ComputedChecksum
+
+	// Older files end with the footer.
+	if hasFooter && offset+len(footerMagic) < len(data) {
+		if err := file.readMetadata(data[offset+len(footerMagic):]); err != nil {
+			err = fmt.Errorf("error reading metadata: %v", err)
+			if strict {
+				return nil, nil, err
+			}
+			p.errors = append(p.errors, kooky.CookieError{RowID: -1, Stage: kooky.StageParse, Err: err})
+		}
+	}
+
+	return file, p.errors, nil
+}
+
+// readMetadata decodes the property list following the footer.
+func (file *File) readMetadata(data []byte) error {
+	plist, err := decodeBplist(data)
+	if err != nil {
+		return err
+	}
+	metadata, ok := plist.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected a dictionary; got %T", plist)
+	}
+
+	file.Metadata = metadata
+	if policy, ok := metadata[acceptPolicyKey].(int64); ok {
+		file.AcceptPolicy = AcceptPolicy(policy)
+	}
+	return nil
+}
+
+// pageChecksum returns the checksum of a page, the sum of every fourth byte.
+func pageChecksum(page []byte) uint32 {
+	var checksum uint32
+	for i := 0; i < len(page); i += 4 {
+		checksum += uint32(page[i])
+	}
+	return checksum
+}
+
+// pageReader accumulates the cookies of the pages of one file, and in
+// lenient mode the errors of the cookies it skipped.
+type pageReader struct {
+	strict  bool
+	ordinal int64
+
+	cookies []*kooky.Cookie
+	errors  []kooky.CookieError
+}
+
+func (p *pageReader) readPage(page []byte) error {
+	r := bytes.NewReader(page)
+
+	var header pageHeader
+	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
+		return fmt.Errorf("error reading header: %v", err)
+	}
+	want := [4]byte{0x00, 0x00, 0x01, 0x00}
+	if header.Header != want {
+		return fmt.Errorf("expected first 4 bytes of page to be %v; got %v", want, header.Header)
+	}
+	if header.NumCookies < 0 || int64(header.NumCookies)*4 > int64(r.Len()) {
+		return fmt.Errorf("error reading cookie offsets: %v", io.ErrUnexpectedEOF)
+	}
+
+	cookieOffsets := make([]int32, header.NumCookies)
+	if err := binary.Read(r, binary.LittleEndian, &cookieOffsets); err != nil {
+		return fmt.Errorf("error reading cookie offsets: %v", err)
+	}
+
+	for i, cookieOffset := range cookieOffsets {
+		ordinal := p.ordinal
+		p.ordinal++
+
+		r.Seek(int64(cookieOffset), io.SeekStart)
+		cookie, err := readCookie(r)
+		if err != nil {
+			if p.strict {
+				return fmt.Errorf("cookie %d: %v", i, err)
+			}
+			p.errors = append(p.errors, kooky.CookieError{RowID: ordinal, Stage: kooky.StageParse, Err: err})
+			continue
+		}
+		p.cookies = append(p.cookies, cookie)
+	}
+
+	return nil
+}
@@ -5,17 +5,20 @@ package safari
 
 import (
 	"bufio"
-	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"io/ioutil"
 	"math"
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
 	"time"
 
+	"github.com/kgoins/kooky/internal/storefs"
 	kooky "github.com/kgoins/kooky/pkg"
 )
 
@@ -29,20 +32,40 @@ type pageHeader struct {
 	NumCookies int32
 }
 
+// cookieHeader is the fixed part of a cookie record. It is followed by the
+// NUL-terminated strings the offsets, relative to the start of the record,
+// point to. The comment offsets are zero for cookies without one.
 type cookieHeader struct {
-	Size           int32
-	Unknown1       int32
-	Flags          int32
-	Unknown2       int32
-	URLOffset      int32
-	NameOffset     int32
-	PathOffset     int32
-	ValueOffset    int32
-	End            [8]byte
-	ExpirationDate float64
-	CreationDate   float64
+	Size             int32
+	Version          int32
+	Flags            int32
+	Unknown2         int32
+	URLOffset        int32
+	NameOffset       int32
+	PathOffset       int32
+	ValueOffset      int32
+	CommentOffset    int32
+	CommentURLOffset int32
+	ExpirationDate   float64
+	CreationDate     float64
 }
 
+// Cookie flags. Apple does not document them, and Safari's SameSite
+// policy is not known to be among them.
+const (
+	flagSecure   = 0x01
+	flagHTTPOnly = 0x04
+)
+
+// macEpoch is the start of Mac absolute time, Jan 1 2001, in Unix seconds.
+const macEpoch = 978307200
+
+// noDate stands for a missing expiry or creation date in a record.
+const noDate = 0
+
+// defaultProfile names the profile whose cookies are kept in the default location.
+const defaultProfile = "Default"
+
 var cookiePathMap kooky.DefaultPathMap
 var installLocationPathMap kooky.DefaultPathMap
 
@@ -58,14 +81,36 @@ func init() {
 type CookieReader struct {
 	cookiePathMap          kooky.DefaultPathMap
 	installLocationPathMap kooky.DefaultPathMap
+
+	strict bool
+	// profile is set on the cookies read: defaultProfile for Safari's
+	// store, the bundle ID for the stores of other apps.
+	profile string
+}
+
+// Option configures a CookieReader.
+type Option func(*CookieReader)
+
+// WithStrict rejects files that are truncated or whose checksum does not
+// match with ErrNotIntact, instead of reading what they contain.
+func WithStrict() Option {
+	return func(reader *CookieReader) {
+		reader.strict = true
+	}
 }
 
 // NewCookieReader returns a new CookieReader
-func NewCookieReader() CookieReader {
-	return CookieReader{
+func NewCookieReader(options ...Option) CookieReader {
+	reader := CookieReader{
 		cookiePathMap:          cookiePathMap,
 		installLocationPathMap: installLocationPathMap,
+		profile:                defaultProfile,
 	}
+	for _, option := range options {
+		option(&reader)
+	}
+
+	return reader
 }
 
 // GetDefaultInstallPath returns the absolute filepath for the default install location on the current OS.
@@ -90,100 +135,118 @@ func (reader CookieReader) GetDefaultCookieFilePath(operatingSystem string) (str
 		return "", err
 	}
 
-	return filepath.Join(currentUser.HomeDir, path), nil
-}
+	// Sandboxed versions of Safari keep their cookies in their container.
+	if operatingSystem == "darwin" {
+		containerPath := filepath.Join(currentUser.HomeDir, filepath.FromSlash(containerCookiePath))
+		if _, err := os.Stat(containerPath); err == nil {
+			return containerPath, nil
+		}
+	}
 
-// ReadAllCookies reads all cookies from the input safari cookie database filepath.
-func (reader CookieReader) ReadAllCookies(filename string) ([]*kooky.Cookie, error) {
-	return reader.ReadCookies(filename, "", "", time.Time{})
+	return filepath.Join(currentUser.HomeDir, path), nil
 }
 
-// ReadCookies reads cookies from the input safari cookie database filepath, filtered by the input parameters.
-func (reader CookieReader) ReadCookies(filename string, domainFilter string, nameFilter string, expireAfter time.Time) ([]*kooky.Cookie, error) {
-	var allCookies []*kooky.Cookie
-
-	f, err := os.Open(filename)
+// ListProfiles returns the default Safari profile, the only one kooky knows about, if it has a cookie file.
+func (reader CookieReader) ListProfiles(operatingSystem string) ([]kooky.Profile, error) {
+	cookieFile, err := reader.GetDefaultCookieFilePath(operatingSystem)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
+	if _, err := os.Stat(cookieFile); err != nil {
+		return nil, nil
+	}
+
+	return []kooky.Profile{{
+		ID:         defaultProfile,
+		Name:       defaultProfile,
+		Dir:        filepath.Dir(cookieFile),
+		CookieFile: cookieFile,
+	}}, nil
+}
 
-	var header fileHeader
-	err = binary.Read(f, binary.BigEndian, &header)
+// GetProfileCookieFilePath returns the cookie file of the profile with the given name.
+func (reader CookieReader) GetProfileCookieFilePath(operatingSystem string, profile string) (string, error) {
+	profiles, err := reader.ListProfiles(operatingSystem)
 	if err != nil {
-		return nil, fmt.Errorf("error reading header: %v", err)
-	}
-	if string(header.Magic[:]) != "cook" {
-		return nil, fmt.Errorf("expected first 4 bytes to be %q; got %q", "cook", string(header.Magic[:]))
+		return "", err
 	}
 
-	pageSizes := make([]int32, header.NumPages)
-	if err = binary.Read(f, binary.BigEndian, &pageSizes); err != nil {
-		return nil, fmt.Errorf("error reading page sizes: %v", err)
+	found := kooky.FindProfile(profile, profiles)
+	if found == nil {
+		return "", kooky.ErrProfileNotFound
 	}
+	return found.CookieFile, nil
+}
 
-	for i, pageSize := range pageSizes {
-		if allCookies, err = readPage(f, pageSize, allCookies); err != nil {
-			return nil, fmt.Errorf("error reading page %d: %v", i, err)
-		}
-	}
+// ReadAllCookies reads all cookies from the input safari cookie database filepath.
+func (reader CookieReader) ReadAllCookies(filename string) ([]*kooky.Cookie, error) {
+	return reader.ReadCookies(filename)
+}
 
-	// TODO(zellyn): figure out how the checksum works.
-	var checksum [8]byte
-	err = binary.Read(f, binary.BigEndian, &checksum)
-	if err != nil {
-		return nil, fmt.Errorf("error reading checksum: %v", err)
-	}
+// ReadCookies reads cookies from the input safari cookie database filepath, filtered by the input filters.
+// With WithStrict, files that are truncated or fail their checksum are rejected with ErrNotIntact.
+func (reader CookieReader) ReadCookies(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.OS(), filename, true, filters)
+	return cookies, err
+}
 
-	// Filter cookies by specified filters.
-	var cookies []*kooky.Cookie
-	for _, cookie := range allCookies {
-		if domainFilter != "" && domainFilter != cookie.Domain {
-			continue
-		}
-		if nameFilter != "" && nameFilter != cookie.Name {
-			continue
-		}
-		if !cookie.Expires.IsZero() && cookie.Expires.Before(expireAfter) {
-			continue
-		}
+// ReadCookiesLenient reads cookies like ReadCookies, but skips the cookies and pages it fails to read,
+// returning an error for each of them. The RowID of a cookie error is the position of the cookie in the file.
+func (reader CookieReader) ReadCookiesLenient(filename string, filters ...kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	return reader.readCookies(storefs.OS(), filename, false, filters)
+}
 
-		cookies = append(cookies, cookie)
-	}
-	return cookies, nil
+// ReadCookiesFS reads cookies like ReadCookies from the file name in fsys, e.g. a store in a zip archive
+// of a home directory.
+func (reader CookieReader) ReadCookiesFS(fsys fs.FS, name string, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	cookies, _, err := reader.readCookies(storefs.FromFS(fsys), name, true, filters)
+	return cookies, err
 }
 
-func readPage(f io.Reader, pageSize int32, cookies []*kooky.Cookie) ([]*kooky.Cookie, error) {
-	bb := make([]byte, pageSize)
-	if _, err := io.ReadFull(f, bb); err != nil {
+// ReadCookiesFrom reads cookies like ReadCookies from the size bytes of a binarycookies file in r.
+func (reader CookieReader) ReadCookiesFrom(r io.ReaderAt, size int64, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	data, err := ioutil.ReadAll(io.NewSectionReader(r, 0, size))
+	if err != nil {
 		return nil, err
 	}
-	r := bytes.NewReader(bb)
 
-	var header pageHeader
-	if err := binary.Read(r, binary.LittleEndian, &header); err != nil {
-		return nil, fmt.Errorf("error reading header: %v", err)
-	}
-	want := [4]byte{0x00, 0x00, 0x01, 0x00}
-	if header.Header != want {
-		return nil, fmt.Errorf("expected first 4 bytes of page to be %v; got %v", want, header.Header)
+	cookies, _, err := reader.readCookies(storefs.Memory(data), "", true, filters)
+	return cookies, err
+}
+
+// readCookies reads the cookies of filename in files. In strict mode the first cookie or page that fails aborts the read.
+// A file that is not intact aborts it if the reader was created WithStrict.
+func (reader CookieReader) readCookies(files storefs.FS, filename string, strict bool, filters []kooky.Filter) ([]*kooky.Cookie, []kooky.CookieError, error) {
+	data, err := files.ReadFile(filename)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	cookieOffsets := make([]int32, header.NumCookies)
-	if err := binary.Read(r, binary.LittleEndian, &cookieOffsets); err != nil {
-		return nil, fmt.Errorf("error reading cookie offsets: %v", err)
+	file, cookieErrors, err := parseFile(data, strict)
+	if err != nil {
+		// A truncated file fails to parse before its checksum is read.
+		if reader.strict {
+			if file, _, lenientErr := parseFile(data, false); lenientErr == nil && !file.Intact {
+				return nil, nil, ErrNotIntact
+			}
+		}
+		return nil, nil, err
+	}
+	if reader.strict && !file.Intact {
+		return nil, nil, ErrNotIntact
 	}
 
-	for i, cookieOffset := range cookieOffsets {
-		r.Seek(int64(cookieOffset), io.SeekStart)
-		cookie, err := readCookie(r)
-		if err != nil {
-			return nil, fmt.Errorf("cookie %d: %v", i, err)
+	for _, cookie := range file.Cookies {
+		cookie.File = filename
+		// A cookie file read from memory has no profile.
+		if filename != "" {
+			cookie.Profile = reader.profile
 		}
-		cookies = append(cookies, cookie)
 	}
 
-	return cookies, nil
+	// The pages of a store are only known to be intact once all are read, so
+	// unlike the other readers this one filters after decoding.
+	return kooky.FilterCookies(file.Cookies, filters...), cookieErrors, nil
 }
 
 func readCookie(r io.ReadSeeker) (*kooky.Cookie, error) {
@@ -193,9 +256,6 @@ func readCookie(r io.ReadSeeker) (*kooky.Cookie, error) {
 		return nil, err
 	}
 
-	expiry := safariCookieDate(ch.ExpirationDate)
-	creation := safariCookieDate(ch.CreationDate)
-
 	url, err := readString(r, "url", start, ch.URLOffset)
 	if err != nil {
 		return nil, err
@@ -212,16 +272,35 @@ func readCookie(r io.ReadSeeker) (*kooky.Cookie, error) {
 	if err != nil {
 		return nil, err
 	}
+	comment, err := readOptionalString(r, "comment", start, ch.CommentOffset)
+	if err != nil {
+		return nil, err
+	}
+	commentURL, err := readOptionalString(r, "comment url", start, ch.CommentURLOffset)
+	if err != nil {
+		return nil, err
+	}
 
 	cookie := &kooky.Cookie{}
-	cookie.Expires = expiry
-	cookie.Creation = creation
+	// Safari does not write session cookies to disk; WriteCookies writes
+	// cookies without a date with noDate, which is read back as the zero time.
+	if ch.ExpirationDate != noDate {
+		cookie.Expires = safariCookieDate(ch.ExpirationDate)
+		cookie.Persistent = true
+	}
+	if ch.CreationDate != noDate {
+		cookie.Creation = safariCookieDate(ch.CreationDate)
+	}
 	cookie.Name = name
 	cookie.Value = value
 	cookie.Domain = url
 	cookie.Path = path
-	cookie.Secure = (ch.Flags & 1) > 0
-	cookie.HttpOnly = (ch.Flags & 4) > 0
+	cookie.Secure = (ch.Flags & flagSecure) > 0
+	cookie.HttpOnly = (ch.Flags & flagHTTPOnly) > 0
+	cookie.Comment = comment
+	cookie.CommentURL = commentURL
+	cookie.HostOnly = !strings.HasPrefix(url, ".")
+	cookie.Browser = "safari"
 
 	return cookie, nil
 }
@@ -240,9 +319,18 @@ func readString(r io.ReadSeeker, field string, start int64, offset int32) (strin
 	return value[:len(value)-1], nil
 }
 
+// readOptionalString reads a string like readString, except that an offset
+// of zero marks an absent string.
+func readOptionalString(r io.ReadSeeker, field string, start int64, offset int32) (string, error) {
+	if offset == 0 {
+		return "", nil
+	}
+	return readString(r, field, start, offset)
+}
+
 // safariCookieDate converts double seconds to a time.Time object,
 // accounting for the switch to Mac epoch (Jan 1 2001).
 func safariCookieDate(floatSecs float64) time.Time {
 	seconds, frac := math.Modf(floatSecs)
-	return time.Unix(int64(seconds)+978307200, int64(frac*1000000000))
+	return time.Unix(int64(seconds)+macEpoch, int64(frac*1000000000))
 }
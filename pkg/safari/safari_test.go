@@ -1,6 +1,15 @@
 package safari
 
 import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -42,4 +51,494 @@ func TestReadSafariCookies(t *testing.T) {
 	if !cookie.Creation.Equal(wantCreation) {
 		t.Errorf("Want cookie.Creation=%v; got %v", wantCreation, cookie.Creation)
 	}
+
+	if !cookie.HostOnly || !cookie.Persistent {
+		t.Errorf("Want cookie.HostOnly and cookie.Persistent; got %v, %v", cookie.HostOnly, cookie.Persistent)
+	}
+	if cookie.Browser != "safari" || cookie.File != testCookiesPath || cookie.Profile != defaultProfile {
+		t.Errorf("Want safari provenance; got browser %q, profile %q, file %q", cookie.Browser, cookie.Profile, cookie.File)
+	}
+}
+
+func TestReadSafariCookiesFiltered(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("small-safari-cookie-db.binarycookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	reader := NewCookieReader()
+
+	cookies, err := reader.ReadCookies(testCookiesPath, kooky.DomainHasSuffix("ycombinator.com"), kooky.Name("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 {
+		t.Fatalf("got %d cookies, but expected 1", len(cookies))
+	}
+
+	cookies, err = reader.ReadCookies(testCookiesPath, kooky.Not(kooky.DomainHasSuffix("ycombinator.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 0 {
+		t.Fatalf("got %d cookies, but expected 0", len(cookies))
+	}
+}
+
+func TestSafariChecksum(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("small-safari-cookie-db.binarycookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	file, err := ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.Intact || file.Checksum != 0x1135 || file.ComputedChecksum != file.Checksum {
+		t.Fatalf("Want an intact file with checksum 0x1135; got intact %v, checksum %#x, computed %#x", file.Intact, file.Checksum, file.ComputedChecksum)
+	}
+
+	data, err := ioutil.ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	corrupted := append([]byte(nil), data...)
+	corrupted[8+4*2+4*4] ^= 0x01 // a byte counted by the checksum of the first page
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"corrupted", corrupted},
+		{"truncated footer", data[:len(data)-80]},
+		{"truncated page", data[:200]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			file, _, err := parseFile(test.data, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Intact {
+				t.Errorf("Want a file that is not intact")
+			}
+
+			dir, err := ioutil.TempDir("", "kooky")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			filename := filepath.Join(dir, "Cookies.binarycookies")
+			if err := ioutil.WriteFile(filename, test.data, 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := NewCookieReader(WithStrict()).ReadCookies(filename); err != ErrNotIntact {
+				t.Errorf("Want ErrNotIntact; got %v", err)
+			}
+			if _, _, err := NewCookieReader(WithStrict()).ReadCookiesLenient(filename); err != ErrNotIntact {
+				t.Errorf("Want ErrNotIntact reading leniently; got %v", err)
+			}
+			if _, err := NewCookieReader().ReadCookies(filename); err == ErrNotIntact {
+				t.Errorf("Want the file read without strict mode; got %v", err)
+			}
+			if _, _, err := NewCookieReader().ReadCookiesLenient(filename); err != nil {
+				t.Errorf("Want the file read leniently; got %v", err)
+			}
+			if file, err := ReadFile(filename); err != nil || file.Intact {
+				t.Errorf("Want ReadFile to read a file that is not intact; got %v", err)
+			}
+		})
+	}
+}
+
+func TestWriteSafariCookies(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("small-safari-cookie-db.binarycookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	want, err := ioutil.ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := NewCookieReader().ReadAllCookies(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCookies(&buf, cookies); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("Written file differs from %s:\n%x\nwant:\n%x", testCookiesPath, buf.Bytes(), want)
+	}
+
+	written := []*kooky.Cookie{{
+		Domain:   ".example.com",
+		Name:     "session",
+		Path:     "/",
+		Value:    "abc123",
+		Secure:   true,
+		HttpOnly: true,
+		Expires:  time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		Creation: time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+	}, {
+		Domain: ".example.com",
+		Name:   "prefs",
+		Path:   "/settings",
+		Value:  "dark",
+	}, {
+		Domain: "www.example.org",
+		Name:   "id",
+		Path:   "/",
+		Value:  "42",
+	}}
+	buf.Reset()
+	if err := WriteCookies(&buf, written); err != nil {
+		t.Fatal(err)
+	}
+	file, _, err := parseFile(buf.Bytes(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.Intact || len(file.Cookies) != len(written) {
+		t.Fatalf("Want an intact file with %d cookies; got intact %v, %d cookies", len(written), file.Intact, len(file.Cookies))
+	}
+	for i, cookie := range file.Cookies {
+		w := written[i]
+		if cookie.Domain != w.Domain || cookie.Name != w.Name || cookie.Path != w.Path || cookie.Value != w.Value ||
+			cookie.Secure != w.Secure || cookie.HttpOnly != w.HttpOnly || !cookie.Expires.Equal(w.Expires) ||
+			!cookie.Creation.Equal(w.Creation) || cookie.Persistent != !w.Expires.IsZero() {
+			t.Errorf("Cookie %d: want %+v; got %+v", i, w, cookie)
+		}
+	}
+}
+
+func TestReadSafariMetadata(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("small-safari-cookie-db.binarycookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	file, err := ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.AcceptPolicy != AcceptPolicyOnlyFromMainDocumentDomain || !file.AcceptPolicy.BlocksThirdPartyCookies() {
+		t.Errorf("Want accept policy %v; got %v", AcceptPolicyOnlyFromMainDocumentDomain, file.AcceptPolicy)
+	}
+	if len(file.Metadata) != 1 {
+		t.Errorf("Want 1 metadata key; got %v", file.Metadata)
+	}
+
+	// The metadata is written back, with the accept policy set on the file.
+	file.Metadata["Other"] = "kept"
+	file.AcceptPolicy = AcceptPolicyAlways
+	var buf bytes.Buffer
+	if err := WriteFile(&buf, file); err != nil {
+		t.Fatal(err)
+	}
+	written, _, err := parseFile(buf.Bytes(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written.AcceptPolicy != AcceptPolicyAlways || written.Metadata["Other"] != "kept" || len(written.Cookies) != len(file.Cookies) {
+		t.Errorf("Want accept policy %v and the metadata written; got %v, %v", AcceptPolicyAlways, written.AcceptPolicy, written.Metadata)
+	}
+}
+
+func TestDecodeBplist(t *testing.T) {
+	// plistlib.dumps(..., fmt=plistlib.FMT_BINARY, sort_keys=True)
+	data, err := hex.DecodeString("62706c6973743030d80102030405060708090a0b0c0d0e11125342696754426c6f6257456e61626c6564544c6f6e67" +
+		"5f10184e5348545450436f6f6b6965416363657074506f6c696379554e616d657355526174696f545768656e130000010000000000420102095f1014" +
+		"78787878787878787878787878787878787878781001a20f106400630061006600e955706c61696e233fe00000000000003341c24292a00000000819" +
+		"1d222a2f4a50565b6467687f81848d939c00000000000001010000000000000013000000000000000000000000000000a5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plist, err := decodeBplist(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"NSHTTPCookieAcceptPolicy": int64(1),
+		"Names":                    []interface{}{"café", "plain"},
+		"Enabled":                  true,
+		"Ratio":                    0.5,
+		"Big":                      int64(1 << 40),
+		"Blob":                     []byte{0x01, 0x02},
+		"When":                     time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		"Long":                     strings.Repeat("x", 20),
+	}
+	got, ok := plist.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Want a dictionary; got %T", plist)
+	}
+	when, _ := got["When"].(time.Time)
+	if !when.Equal(want["When"].(time.Time)) {
+		t.Errorf("Want When=%v; got %v", want["When"], got["When"])
+	}
+	delete(got, "When")
+	delete(want, "When")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want %v; got %v", want, got)
+	}
+
+	if _, err := decodeBplist(data[:len(data)-40]); err == nil {
+		t.Errorf("Want an error decoding a truncated property list")
+	}
+
+	// Arrays referencing the next one twice, 30 deep, would take 2^30
+	// decodes.
+	const levels = 30
+	fanOut := []byte("bplist00")
+	var offsets []byte
+	for i := 0; i < levels; i++ {
+		offsets = append(offsets, byte(len(fanOut)))
+		fanOut = append(fanOut, 0xa2, byte(i+1), byte(i+1))
+	}
+	offsets = append(offsets, byte(len(fanOut)))
+	fanOut = append(fanOut, 0x00)
+	offsetTable := len(fanOut)
+	fanOut = append(fanOut, offsets...)
+	trailer := make([]byte, bplistTrailerSize)
+	trailer[6], trailer[7] = 1, 1
+	binary.BigEndian.PutUint64(trailer[8:], uint64(len(offsets)))
+	binary.BigEndian.PutUint64(trailer[24:], uint64(offsetTable))
+	fanOut = append(fanOut, trailer...)
+	if _, err := decodeBplist(fanOut); err == nil {
+		t.Errorf("Want an error decoding a property list referencing objects too often")
+	}
+}
+
+func TestEncodeBplist(t *testing.T) {
+	want := map[string]interface{}{
+		"NSHTTPCookieAcceptPolicy": int64(2),
+		"Names":                    []interface{}{"café", "plain", strings.Repeat("y", 300)},
+		"Enabled":                  false,
+		"Ratio":                    -1.5,
+		"Big":                      int64(1 << 40),
+		"Negative":                 int64(-3),
+		"Blob":                     []byte{0x01, 0x02},
+		"UID":                      uint64(70000),
+		"When":                     time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC),
+		"Nested":                   map[string]interface{}{"Long": strings.Repeat("x", 20)},
+	}
+	data, err := encodeBplist(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plist, err := decodeBplist(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := plist.(map[string]interface{})
+	if !ok {
+		t.Fatalf("Want a dictionary; got %T", plist)
+	}
+	when, _ := got["When"].(time.Time)
+	if !when.Equal(want["When"].(time.Time)) {
+		t.Errorf("Want When=%v; got %v", want["When"], got["When"])
+	}
+	delete(got, "When")
+	delete(want, "When")
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want %v; got %v", want, got)
+	}
+
+	if _, err := encodeBplist(map[string]interface{}{"Bad": struct{}{}}); err == nil {
+		t.Errorf("Want an error encoding an unsupported type")
+	}
+}
+
+func TestFindSafariStores(t *testing.T) {
+	home, err := testutils.GetTestDataFilePath("safari-home")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+
+	stores, err := FindStores(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	library := filepath.Join(home, "Library")
+	want := []Store{
+		{"com.apple.Safari", filepath.Join(library, "Cookies", "Cookies.binarycookies")},
+		{"com.example.Legacy", filepath.Join(library, "Cookies", "com.example.Legacy.binarycookies")},
+		{"com.example.App", filepath.Join(library, "HTTPStorages", "com.example.App.binarycookies")},
+		{"com.apple.Safari", filepath.Join(library, "Containers", "com.apple.Safari", "Data", "Library", "Cookies", "Cookies.binarycookies")},
+		{"com.example.Sandboxed", filepath.Join(library, "Containers", "com.example.Sandboxed", "Data", "Library", "HTTPStorages", "com.example.Sandboxed.binarycookies")},
+		{"group.com.example.shared", filepath.Join(library, "Group Containers", "group.com.example.shared", "Library", "Cookies", "Cookies.binarycookies")},
+	}
+	if !reflect.DeepEqual(stores, want) {
+		t.Fatalf("Want stores %v; got %v", want, stores)
+	}
+
+	cookies, err := NewCookieReader().ReadStoreCookies(stores[2], kooky.Name("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Profile != "com.example.App" || cookies[0].File != stores[2].File {
+		t.Fatalf("Want 1 cookie of com.example.App; got %v", cookies)
+	}
+
+	ofApp := func(cookie *kooky.Cookie) bool { return cookie.Profile == "com.example.App" }
+	if cookies, err := NewCookieReader().ReadStoreCookies(stores[2], ofApp); err != nil || len(cookies) == 0 {
+		t.Fatalf("Want the filters to see the profile of the store; got %v, %v", cookies, err)
+	}
+
+	// Safari's own stores have the profile ListProfiles names.
+	cookies, err = NewCookieReader().ReadStoreCookies(stores[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) == 0 || cookies[0].Profile != defaultProfile {
+		t.Errorf("Want cookies of the %s profile; got %v", defaultProfile, cookies)
+	}
+}
+
+func TestFindSafariStoresUnreadable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root may read any directory")
+	}
+
+	home, err := ioutil.TempDir("", "kooky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(home)
+
+	containers := filepath.Join(home, "Library", "Containers")
+	protected := filepath.Join(containers, "com.example.Protected")
+	cookies := filepath.Join(containers, "com.example.Open", "Data", "Library", "Cookies")
+	for _, dir := range []string{protected, cookies} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(cookies, sharedStoreName), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(protected, 0); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chmod(protected, 0755)
+
+	stores, err := FindStores(home)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stores) != 1 || stores[0].BundleID != "com.example.Open" {
+		t.Fatalf("Want the store of com.example.Open; got %v", stores)
+	}
+}
+
+// syntheticRecord assembles a cookie record by hand: a header with the given
+// flags, followed by the strings, an empty one marking an absent comment or
+// comment URL.
+func syntheticRecord(flags int32, strs ...string) []byte {
+	header := make([]byte, 56)
+	binary.LittleEndian.PutUint32(header[8:], uint32(flags))
+	binary.LittleEndian.PutUint64(header[40:], math.Float64bits(915148800)) // 2030-01-01
+	binary.LittleEndian.PutUint64(header[48:], math.Float64bits(631195200)) // 2021-01-01 12:00
+
+	record := header
+	for i, s := range strs {
+		if s == "" && i >= 4 {
+			continue
+		}
+		binary.LittleEndian.PutUint32(record[16+4*i:], uint32(len(record)))
+		record = append(record, s...)
+		record = append(record, 0)
+	}
+	binary.LittleEndian.PutUint32(record, uint32(len(record)))
+	return record
+}
+
+func TestReadSafariCookieRecord(t *testing.T) {
+	tests := []struct {
+		name   string
+		record []byte
+		want   kooky.Cookie
+	}{{
+		name:   "plain",
+		record: syntheticRecord(0, ".example.com", "plain", "/", "1"),
+		want:   kooky.Cookie{Domain: ".example.com", Name: "plain", Path: "/", Value: "1"},
+	}, {
+		name:   "flags",
+		record: syntheticRecord(flagSecure|flagHTTPOnly, "example.com", "flags", "/", "2"),
+		want:   kooky.Cookie{Domain: "example.com", Name: "flags", Path: "/", Value: "2", Secure: true, HttpOnly: true},
+	}, {
+		name:   "comment",
+		record: syntheticRecord(0, "example.com", "commented", "/", "3", "a comment", "https://example.com/cookies"),
+		want:   kooky.Cookie{Domain: "example.com", Name: "commented", Path: "/", Value: "3", Comment: "a comment", CommentURL: "https://example.com/cookies"},
+	}, {
+		name:   "comment url only",
+		record: syntheticRecord(0, "example.com", "policy", "/", "4", "", "https://example.com/policy"),
+		want:   kooky.Cookie{Domain: "example.com", Name: "policy", Path: "/", Value: "4", CommentURL: "https://example.com/policy"},
+	}}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cookie, err := readCookie(bytes.NewReader(test.record))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := test.want
+			want.Expires = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+			want.Creation = time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+			want.HostOnly = !strings.HasPrefix(want.Domain, ".")
+			want.Persistent = true
+			want.Browser = "safari"
+			if !cookie.Expires.Equal(want.Expires) || !cookie.Creation.Equal(want.Creation) {
+				t.Errorf("Want expires %v, creation %v; got %v, %v", want.Expires, want.Creation, cookie.Expires, cookie.Creation)
+			}
+			cookie.Expires, cookie.Creation = want.Expires, want.Creation
+			if !reflect.DeepEqual(*cookie, want) {
+				t.Errorf("Want %+v; got %+v", want, *cookie)
+			}
+
+			if encoded := encodeCookie(cookie); !bytes.Equal(encoded, test.record) {
+				t.Errorf("Encoded record differs:\n%x\nwant:\n%x", encoded, test.record)
+			}
+		})
+	}
+}
+
+func TestReadSafariCookiesFrom(t *testing.T) {
+	testCookiesPath, err := testutils.GetTestDataFilePath("small-safari-cookie-db.binarycookies")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	data, err := ioutil.ReadFile(testCookiesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cookies, err := NewCookieReader().ReadCookiesFrom(bytes.NewReader(data), int64(len(data)), kooky.Name("user"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 1 || cookies[0].Domain != "news.ycombinator.com" || cookies[0].File != "" {
+		t.Fatalf("want the user cookie of news.ycombinator.com; got %v", cookies)
+	}
+
+	home, err := testutils.GetTestDataFilePath("safari-home")
+	if err != nil {
+		t.Fatalf("Failed to load test data file")
+	}
+	name := "Library/HTTPStorages/com.example.App.binarycookies"
+	cookies, err = NewCookieReader().ReadCookiesFS(os.DirFS(home), name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 || cookies[0].File != name {
+		t.Fatalf("want 2 cookies of %s; got %v", name, cookies)
+	}
 }
@@ -0,0 +1,137 @@
+package safari
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/kgoins/kooky/internal/storefs"
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+const (
+	safariBundleID = "com.apple.Safari"
+
+	storeExt        = ".binarycookies"
+	sharedStoreName = "Cookies" + storeExt
+
+	// containerCookiePath is the cookie file of sandboxed Safari, relative to
+	// the home directory.
+	containerCookiePath = "Library/Containers/" + safariBundleID + "/Data/Library/Cookies/" + sharedStoreName
+)
+
+// Store is a binarycookies file and the app it belongs to.
+type Store struct {
+	// BundleID identifies the app, or for group containers the app group,
+	// owning the store.
+	BundleID string
+	File     string
+}
+
+// FindStores lists the binarycookies stores of Safari and of the apps
+// using WebKit or NSURLSession under the macOS home directory home, which
+// may be a copy on another system. The stores are looked for in:
+//
+//	Library/Cookies
+//	Library/HTTPStorages
+//	Library/Containers/<bundle ID>/Data/Library/{Cookies,HTTPStorages}
+//	Library/Group Containers/<group>/Library/{Cookies,HTTPStorages}
+//
+// A store named after a bundle ID belongs to that app; Cookies.binarycookies
+// belongs to the container holding it, or outside of one to Safari.
+// Directories that may not be read, like the containers macOS protects from
+// other apps, are skipped.
+func FindStores(home string) ([]Store, error) {
+	library := filepath.Join(home, "Library")
+
+	stores, err := findStores(library, safariBundleID)
+	if err != nil {
+		return nil, err
+	}
+
+	containers := []struct {
+		dir     string
+		library string
+	}{
+		{filepath.Join(library, "Containers"), filepath.Join("Data", "Library")},
+		{filepath.Join(library, "Group Containers"), "Library"},
+	}
+	for _, c := range containers {
+		entries, err := readDirNames(c.dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, container := range entries {
+			found, err := findStores(filepath.Join(c.dir, container, c.library), container)
+			if err != nil {
+				return nil, err
+			}
+			stores = append(stores, found...)
+		}
+	}
+
+	return stores, nil
+}
+
+// findStores lists the stores in the Cookies and HTTPStorages directories
+// of library, attributing shared stores to owner.
+func findStores(library string, owner string) ([]Store, error) {
+	var stores []Store
+	for _, dir := range []string{"Cookies", "HTTPStorages"} {
+		names, err := readDirNames(filepath.Join(library, dir))
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			if !strings.HasSuffix(name, storeExt) {
+				continue
+			}
+			file := filepath.Join(library, dir, name)
+			if info, err := os.Stat(file); err != nil || !info.Mode().IsRegular() {
+				continue
+			}
+
+			bundleID := strings.TrimSuffix(name, storeExt)
+			if name == sharedStoreName {
+				bundleID = owner
+			}
+			stores = append(stores, Store{BundleID: bundleID, File: file})
+		}
+	}
+
+	return stores, nil
+}
+
+// readDirNames returns the sorted names in dir, or none if it does not exist
+// or may not be read, as the containers of other apps often may not be.
+func readDirNames(dir string) ([]string, error) {
+	f, err := os.Open(dir)
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ReadStoreCookies reads the cookies of store, filtered by the input
+// filters. The profile of each cookie is set before the filters see it: it
+// is the profile ListProfiles returns for Safari's stores, and the bundle ID
+// of the store for those of other apps.
+func (reader CookieReader) ReadStoreCookies(store Store, filters ...kooky.Filter) ([]*kooky.Cookie, error) {
+	reader.profile = store.BundleID
+	if store.BundleID == safariBundleID {
+		reader.profile = defaultProfile
+	}
+	cookies, _, err := reader.readCookies(storefs.OS(), store.File, true, filters)
+	return cookies, err
+}
@@ -0,0 +1,154 @@
+package safari
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	kooky "github.com/kgoins/kooky/pkg"
+)
+
+// WriteCookies writes cookies to w as a Safari binarycookies file. Like
+// Safari, it puts the cookies of each domain on their own page, in the order
+// the domains first appear, and accepts cookies from the main document
+// domain only.
+func WriteCookies(w io.Writer, cookies []*kooky.Cookie) error {
+	return WriteFile(w, &File{Cookies: cookies, AcceptPolicy: AcceptPolicyOnlyFromMainDocumentDomain})
+}
+
+// WriteFile writes the cookies of file to w like WriteCookies, followed by
+// its Metadata with its AcceptPolicy, unless that is AcceptPolicyUnknown. A
+// file read with ReadFile is written back with the metadata it was read
+// with. The checksum is computed from the pages written.
+func WriteFile(w io.Writer, file *File) error {
+	var domains []string
+	pages := make(map[string][]*kooky.Cookie)
+	for _, cookie := range file.Cookies {
+		if _, ok := pages[cookie.Domain]; !ok {
+			domains = append(domains, cookie.Domain)
+		}
+		pages[cookie.Domain] = append(pages[cookie.Domain], cookie)
+	}
+
+	metadata := make(map[string]interface{}, len(file.Metadata)+1)
+	for key, value := range file.Metadata {
+		metadata[key] = value
+	}
+	if file.AcceptPolicy != AcceptPolicyUnknown {
+		metadata[acceptPolicyKey] = int64(file.AcceptPolicy)
+	}
+	var plist []byte
+	if len(metadata) > 0 {
+		var err error
+		if plist, err = encodeBplist(metadata); err != nil {
+			return fmt.Errorf("error writing metadata: %v", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(fileMagic)
+	binary.Write(&buf, binary.BigEndian, int32(len(domains)))
+
+	encoded := make([][]byte, len(domains))
+	for i, domain := range domains {
+		encoded[i] = encodePage(pages[domain])
+		binary.Write(&buf, binary.BigEndian, int32(len(encoded[i])))
+	}
+
+	var checksum uint32
+	for _, page := range encoded {
+		buf.Write(page)
+		checksum += pageChecksum(page)
+	}
+	binary.Write(&buf, binary.BigEndian, checksum)
+	buf.WriteString(footerMagic)
+	buf.Write(plist)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// encodePage encodes a page: its header, the offsets of its cookies and
+// an empty footer, followed by the cookies.
+func encodePage(cookies []*kooky.Cookie) []byte {
+	records := make([][]byte, len(cookies))
+	for i, cookie := range cookies {
+		records[i] = encodeCookie(cookie)
+	}
+
+	var page bytes.Buffer
+	page.Write([]byte{0x00, 0x00, 0x01, 0x00})
+	binary.Write(&page, binary.LittleEndian, int32(len(cookies)))
+
+	offset := 4 + 4 + 4*len(cookies) + 4
+	for _, record := range records {
+		binary.Write(&page, binary.LittleEndian, int32(offset))
+		offset += len(record)
+	}
+	page.Write([]byte{0x00, 0x00, 0x00, 0x00})
+
+	for _, record := range records {
+		page.Write(record)
+	}
+	return page.Bytes()
+}
+
+// encodeCookie encodes a cookie record: its header followed by its
+// NUL-terminated domain, name, path and value, and comment and comment URL
+// if it has them.
+func encodeCookie(cookie *kooky.Cookie) []byte {
+	var ch cookieHeader
+	offset := int32(binary.Size(ch))
+
+	fields := []struct {
+		value    string
+		offset   *int32
+		optional bool
+	}{
+		{cookie.Domain, &ch.URLOffset, false},
+		{cookie.Name, &ch.NameOffset, false},
+		{cookie.Path, &ch.PathOffset, false},
+		{cookie.Value, &ch.ValueOffset, false},
+		{cookie.Comment, &ch.CommentOffset, true},
+		{cookie.CommentURL, &ch.CommentURLOffset, true},
+	}
+	for _, field := range fields {
+		if field.optional && field.value == "" {
+			continue
+		}
+		*field.offset = offset
+		offset += int32(len(field.value)) + 1
+	}
+	ch.Size = offset
+
+	if cookie.Secure {
+		ch.Flags |= flagSecure
+	}
+	if cookie.HttpOnly {
+		ch.Flags |= flagHTTPOnly
+	}
+	ch.ExpirationDate = macCookieDate(cookie.Expires)
+	ch.CreationDate = macCookieDate(cookie.Creation)
+
+	var record bytes.Buffer
+	binary.Write(&record, binary.LittleEndian, &ch)
+	for _, field := range fields {
+		if field.optional && field.value == "" {
+			continue
+		}
+		record.WriteString(field.value)
+		record.WriteByte(0)
+	}
+	return record.Bytes()
+}
+
+// macCookieDate converts t to seconds since the Mac epoch, the inverse of
+// safariCookieDate. The zero time is written as noDate.
+func macCookieDate(t time.Time) float64 {
+	if t.IsZero() {
+		return noDate
+	}
+	return float64(t.Unix()-macEpoch) + float64(t.Nanosecond())/1e9
+}
@@ -0,0 +1,9 @@
+package vivaldi
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Vivaldi browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("vivaldi")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}
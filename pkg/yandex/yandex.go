@@ -0,0 +1,9 @@
+package yandex
+
+import "github.com/kgoins/kooky/pkg/chrome"
+
+// NewCookieReader returns a new CookieReader for the Yandex browser
+func NewCookieReader(options ...chrome.Option) chrome.CookieReader {
+	browser, _ := chrome.LookupBrowser("yandex")
+	return chrome.NewBrowserCookieReader(browser, options...)
+}